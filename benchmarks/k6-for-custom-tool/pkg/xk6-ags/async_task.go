@@ -1,8 +1,11 @@
 package xk6ags
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"os"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,14 +13,56 @@ import (
 	"github.com/panjf2000/ants/v2"
 )
 
+// AttemptRecord 记录一次任务尝试的时间窗口和结果
+type AttemptRecord struct {
+	StartedAt int64  `json:"started_at"`      // 本次尝试开始时间戳（毫秒）
+	EndedAt   int64  `json:"ended_at"`        // 本次尝试结束时间戳（毫秒）
+	Error     string `json:"error,omitempty"` // 本次尝试的错误信息
+}
+
 // AsyncTaskResult 通用异步任务结果
 type AsyncTaskResult[T any] struct {
-	TaskID     string `json:"task_id"`               // 任务标识
-	Result     T      `json:"result"`                // 任务结果
-	Error      string `json:"error,omitempty"`       // 错误信息
-	StartedAt  int64  `json:"started_at"`            // 开始执行时间戳（毫秒）
-	EndedAt    int64  `json:"ended_at"`              // 结束时间戳（毫秒）
-	DurationMs int64  `json:"duration_ms"`           // 执行耗时（毫秒）
+	TaskID     string          `json:"task_id"`            // 任务标识
+	Result     T               `json:"result"`             // 任务结果
+	Error      string          `json:"error,omitempty"`    // 错误信息
+	StartedAt  int64           `json:"started_at"`         // 开始执行时间戳（毫秒）
+	EndedAt    int64           `json:"ended_at"`           // 结束时间戳（毫秒）
+	DurationMs int64           `json:"duration_ms"`        // 执行耗时（毫秒）
+	Attempts   []AttemptRecord `json:"attempts,omitempty"` // 每次尝试的记录，用于统计重试级联耗时
+}
+
+// RetryPolicy 描述 SubmitWithPolicy 的重试行为
+type RetryPolicy[T any] struct {
+	MaxAttempts       int                 // 最大尝试次数（含首次），<= 1 表示不重试
+	InitialBackoff    time.Duration       // 首次重试前的退避时长
+	BackoffMultiplier float64             // 每次重试退避时长的倍数
+	MaxBackoff        time.Duration       // 退避时长上限
+	Jitter            float64             // 退避抖动比例（0-1），实际退避在 ±Jitter 范围内浮动
+	PerAttemptTimeout time.Duration       // 单次尝试的超时时间，<= 0 表示不限制
+	RetryIf           func(T, error) bool // 判断是否需要重试，nil 表示只要有 error 就重试
+}
+
+// backoffFor 计算第 attempt 次重试（从 1 开始）前应等待的时长，并应用抖动
+func (p RetryPolicy[T]) backoffFor(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	multiplier := p.BackoffMultiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delta := backoff * p.Jitter
+		backoff += (rand.Float64()*2 - 1) * delta
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	return time.Duration(backoff)
 }
 
 // AsyncTaskExecutor 通用异步任务执行器
@@ -29,6 +74,9 @@ type AsyncTaskExecutor[T any] struct {
 	results      []*AsyncTaskResult[T]
 	resultsLock  sync.Mutex
 	pendingCount int64
+	ctx          context.Context // 共享上下文，取消后所有在途重试停止
+	cancel       context.CancelFunc
+	store        Store // 可选的持久化存储，用于进程重启后恢复任务
 }
 
 // NewAsyncTaskExecutor 创建异步任务执行器
@@ -36,12 +84,25 @@ func NewAsyncTaskExecutor[T any](name string, poolSize int) *AsyncTaskExecutor[T
 	if poolSize <= 0 {
 		poolSize = 1e5
 	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &AsyncTaskExecutor[T]{
 		name:     name,
 		poolSize: poolSize,
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
+// Stop 取消执行器的共享上下文，正在等待重试的任务会停止，不再发起下一次尝试
+func (e *AsyncTaskExecutor[T]) Stop() {
+	e.cancel()
+}
+
+// SetStore 绑定持久化存储，绑定后 SubmitDurable 提交的任务可在进程重启后通过 RestoreDurable 恢复
+func (e *AsyncTaskExecutor[T]) SetStore(store Store) {
+	e.store = store
+}
+
 // getPool 延迟初始化 goroutine 池
 func (e *AsyncTaskExecutor[T]) getPool() *ants.Pool {
 	e.poolOnce.Do(func() {
@@ -99,6 +160,184 @@ func (e *AsyncTaskExecutor[T]) Submit(taskID string, delaySeconds int, taskFunc
 	})
 }
 
+// SubmitWithPolicy 按重试策略提交异步任务
+// taskID: 任务标识
+// policy: 重试策略，控制超时、退避和何时重试
+// taskFunc: 任务执行函数，接收每次尝试的超时 context
+func (e *AsyncTaskExecutor[T]) SubmitWithPolicy(taskID string, policy RetryPolicy[T], taskFunc func(ctx context.Context) (T, error)) error {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	atomic.AddInt64(&e.pendingCount, 1)
+
+	return e.getPool().Submit(func() {
+		defer atomic.AddInt64(&e.pendingCount, -1)
+
+		startedAt := time.Now().UnixMilli()
+		var (
+			result   T
+			lastErr  error
+			attempts []AttemptRecord
+		)
+
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			attemptStart := time.Now()
+
+			attemptCtx := e.ctx
+			var attemptCancel context.CancelFunc
+			if policy.PerAttemptTimeout > 0 {
+				attemptCtx, attemptCancel = context.WithTimeout(e.ctx, policy.PerAttemptTimeout)
+			}
+
+			result, lastErr = taskFunc(attemptCtx)
+			if attemptCancel != nil {
+				attemptCancel()
+			}
+
+			record := AttemptRecord{
+				StartedAt: attemptStart.UnixMilli(),
+				EndedAt:   time.Now().UnixMilli(),
+			}
+			if lastErr != nil {
+				record.Error = lastErr.Error()
+			}
+			attempts = append(attempts, record)
+
+			if lastErr == nil {
+				break
+			}
+
+			if e.ctx.Err() != nil {
+				break
+			}
+
+			shouldRetry := policy.RetryIf == nil || policy.RetryIf(result, lastErr)
+			if !shouldRetry || attempt >= maxAttempts {
+				break
+			}
+
+			backoff := policy.backoffFor(attempt)
+			if backoff > 0 {
+				timer := time.NewTimer(backoff)
+				select {
+				case <-timer.C:
+				case <-e.ctx.Done():
+					timer.Stop()
+				}
+			}
+
+			if e.ctx.Err() != nil {
+				break
+			}
+		}
+
+		endedAt := time.Now().UnixMilli()
+
+		taskResult := &AsyncTaskResult[T]{
+			TaskID:     taskID,
+			Result:     result,
+			StartedAt:  startedAt,
+			EndedAt:    endedAt,
+			DurationMs: endedAt - startedAt,
+			Attempts:   attempts,
+		}
+		if lastErr != nil {
+			taskResult.Error = lastErr.Error()
+		}
+
+		e.resultsLock.Lock()
+		e.results = append(e.results, taskResult)
+		e.resultsLock.Unlock()
+	})
+}
+
+// SubmitDurable 提交一个持久化任务：先登记到 store（状态 Todo），到期后执行并在 store 中记录最终结果。
+// 即使进程崩溃，重启后调用 RestoreDurable 也能让 runAfter 尚未完成的任务重新执行，
+// 这正是 StartSandboxInstanceWithAsyncStop 需要的：InstanceId + 预定停止时间持久化后，实例仍会被按时停止。
+func (e *AsyncTaskExecutor[T]) SubmitDurable(taskID string, payload []byte, runAfter time.Time, taskFunc func() (T, error)) error {
+	if e.store != nil {
+		if err := e.store.Enqueue(taskID, payload, runAfter); err != nil {
+			return fmt.Errorf("登记持久化任务失败: %w", err)
+		}
+	}
+	return e.runDurable(taskID, runAfter, taskFunc)
+}
+
+// RestoreDurable 从 store 恢复所有处于 Todo 状态的任务并重新提交执行：已到期的任务立即执行，
+// 未到期的按原定 runAfter 调度。rehydrate 根据持久化的 payload 重建任务执行函数。
+func (e *AsyncTaskExecutor[T]) RestoreDurable(rehydrate func(payload []byte) (func() (T, error), error)) error {
+	if e.store == nil {
+		return nil
+	}
+
+	tasks, err := e.store.Claim(time.Now())
+	if err != nil {
+		return fmt.Errorf("恢复持久化任务失败: %w", err)
+	}
+
+	for _, t := range tasks {
+		taskFunc, err := rehydrate(t.Payload)
+		if err != nil {
+			_ = e.store.MarkDone(t.ID, nil, err)
+			continue
+		}
+		// Claim 已经把任务状态置为 Pending 并记录了尝试次数，这里直接调度执行，不再重新 Enqueue
+		if err := e.runDurable(t.ID, t.RunAfter, taskFunc); err != nil {
+			_ = e.store.MarkDone(t.ID, nil, err)
+		}
+	}
+
+	return nil
+}
+
+// runDurable 是 SubmitDurable 和 RestoreDurable 共用的调度逻辑
+func (e *AsyncTaskExecutor[T]) runDurable(taskID string, runAfter time.Time, taskFunc func() (T, error)) error {
+	delay := time.Until(runAfter)
+	if delay < 0 {
+		delay = 0
+	}
+
+	atomic.AddInt64(&e.pendingCount, 1)
+
+	return e.getPool().Submit(func() {
+		defer atomic.AddInt64(&e.pendingCount, -1)
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-e.ctx.Done():
+				timer.Stop()
+			}
+		}
+
+		startedAt := time.Now().UnixMilli()
+		result, err := taskFunc()
+		endedAt := time.Now().UnixMilli()
+
+		taskResult := &AsyncTaskResult[T]{
+			TaskID:     taskID,
+			Result:     result,
+			StartedAt:  startedAt,
+			EndedAt:    endedAt,
+			DurationMs: endedAt - startedAt,
+		}
+		if err != nil {
+			taskResult.Error = err.Error()
+		}
+
+		e.resultsLock.Lock()
+		e.results = append(e.results, taskResult)
+		e.resultsLock.Unlock()
+
+		if e.store != nil {
+			_ = e.store.MarkDone(taskID, nil, err)
+		}
+	})
+}
+
 // GetPendingCount 获取待执行的任务数量
 func (e *AsyncTaskExecutor[T]) GetPendingCount() int64 {
 	return atomic.LoadInt64(&e.pendingCount)
@@ -118,6 +357,7 @@ func (e *AsyncTaskExecutor[T]) GetResults() []*AsyncTaskResult[T] {
 
 // Release 释放资源
 func (e *AsyncTaskExecutor[T]) Release() {
+	e.cancel()
 	if e.pool != nil {
 		e.pool.Release()
 	}
@@ -128,15 +368,27 @@ func (e *AsyncTaskExecutor[T]) Release() {
 // ============================================
 
 var (
-	asyncStopExecutor       *AsyncTaskExecutor[*ControlPlaneResponse]
-	asyncStopExecutorOnce   sync.Once
-	asyncStressExecutor     *AsyncTaskExecutor[*Shell2HttpResponse]
-	asyncStressExecutorOnce sync.Once
+	asyncStopExecutor         *AsyncTaskExecutor[*ControlPlaneResponse]
+	asyncStopExecutorOnce     sync.Once
+	asyncStressExecutor       *AsyncTaskExecutor[*Shell2HttpResponse]
+	asyncStressExecutorOnce   sync.Once
+	asyncHTTPLoadExecutor     *AsyncTaskExecutor[*LoadReport]
+	asyncHTTPLoadExecutorOnce sync.Once
 )
 
 func getAsyncStopExecutor() *AsyncTaskExecutor[*ControlPlaneResponse] {
 	asyncStopExecutorOnce.Do(func() {
 		asyncStopExecutor = NewAsyncTaskExecutor[*ControlPlaneResponse]("async-stop", 1e5)
+
+		// 若配置了快照文件，则绑定持久化存储：xk6 runner 崩溃重启后，
+		// StartSandboxInstanceWithAsyncStop 登记的停止任务可通过 RestoreDurable 恢复执行
+		if path := strings.TrimSpace(os.Getenv("AGS_ASYNC_STOP_STORE_PATH")); path != "" {
+			store := NewFileStore(path)
+			if err := store.Load(); err != nil {
+				panic(fmt.Sprintf("failed to load async-stop store from %s: %v", path, err))
+			}
+			asyncStopExecutor.SetStore(store)
+		}
 	})
 	return asyncStopExecutor
 }
@@ -147,3 +399,10 @@ func getAsyncStressExecutor() *AsyncTaskExecutor[*Shell2HttpResponse] {
 	})
 	return asyncStressExecutor
 }
+
+func getAsyncHTTPLoadExecutor() *AsyncTaskExecutor[*LoadReport] {
+	asyncHTTPLoadExecutorOnce.Do(func() {
+		asyncHTTPLoadExecutor = NewAsyncTaskExecutor[*LoadReport]("async-http-load", 1e5)
+	})
+	return asyncHTTPLoadExecutor
+}
@@ -0,0 +1,291 @@
+package xk6ags
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cls "github.com/tencentcloud/tencentcloud-cls-sdk-go"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// ============================================
+// 控制面调用审计
+// ============================================
+//
+// 和 goscripts/audit 提供的能力对应，但这里独立实现一份：xk6ags 和 goscripts 是两个
+// 互不依赖的 Go module 树，无法共享包。每次 StartSandboxInstance/StopSandboxInstance
+// 调用都会产生一条 auditRecord，经 AUDIT_SINKS 配置的 Sink 落盘/上报，用于事后回放某次
+// 压测期间到底创建、删除过哪些沙箱实例。
+
+// auditRecord 是一条结构化的审计事件
+type auditRecord struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Action    string         `json:"action"`
+	Params    map[string]any `json:"params,omitempty"`
+	Success   bool           `json:"success"`
+	Message   string         `json:"message,omitempty"`
+	RequestID string         `json:"request_id,omitempty"`
+	LatencyMs int64          `json:"latency_ms"`
+	Caller    string         `json:"caller"` // k6 VU+iteration 标识，形如 "vu-3/iter-12"
+}
+
+// auditSink 消费审计事件，具体实现决定落盘方式（本地文件、Kafka、CLS...）
+type auditSink interface {
+	Emit(rec auditRecord) error
+	Close() error
+}
+
+// auditRecorder 把一次调用包装为 auditRecord 并投递给所有配置的 Sink
+type auditRecorder struct {
+	sinks []auditSink
+}
+
+func (r *auditRecorder) emit(action, caller string, params map[string]any, start time.Time, requestID string, err error) {
+	rec := auditRecord{
+		Timestamp: time.Now(),
+		Action:    action,
+		Params:    redactAuditParams(params),
+		Success:   err == nil,
+		RequestID: requestID,
+		LatencyMs: time.Since(start).Milliseconds(),
+		Caller:    caller,
+	}
+	if err != nil {
+		rec.Message = err.Error()
+	}
+
+	for _, sink := range r.sinks {
+		if sinkErr := sink.Emit(rec); sinkErr != nil {
+			log.Printf("failed to write audit record to %T: %v", sink, sinkErr)
+		}
+	}
+}
+
+func (r *auditRecorder) close() {
+	for _, sink := range r.sinks {
+		if err := sink.Close(); err != nil {
+			log.Printf("failed to close audit sink %T: %v", sink, err)
+		}
+	}
+}
+
+// auditRedactKeys 是请求参数中需要脱敏的字段名，不区分大小写匹配
+var auditRedactKeys = map[string]struct{}{
+	"secretkey": {}, "secretid": {}, "token": {}, "password": {}, "authorization": {},
+}
+
+func redactAuditParams(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	redacted := make(map[string]any, len(params))
+	for k, v := range params {
+		if _, ok := auditRedactKeys[strings.ToLower(k)]; ok {
+			redacted[k] = "***REDACTED***"
+			continue
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+// auditJSONLSink 把每条 auditRecord 以 JSON Lines 格式追加写入本地文件
+type auditJSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newAuditJSONLSink(path string) (*auditJSONLSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %w", err)
+	}
+	return &auditJSONLSink{file: file}, nil
+}
+
+func (s *auditJSONLSink) Emit(rec auditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *auditJSONLSink) Close() error {
+	return s.file.Close()
+}
+
+// auditKafkaSink 把每条 auditRecord 序列化为 JSON 后生产到 Kafka topic，Emit 会阻塞等待投递结果
+type auditKafkaSink struct {
+	client *kgo.Client
+	topic  string
+}
+
+func newAuditKafkaSink(brokers []string, topic string) (*auditKafkaSink, error) {
+	client, err := kgo.NewClient(kgo.SeedBrokers(brokers...), kgo.DefaultProduceTopic(topic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	return &auditKafkaSink{client: client, topic: topic}, nil
+}
+
+func (s *auditKafkaSink) Emit(rec auditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	s.client.Produce(nil, &kgo.Record{Topic: s.topic, Value: data}, func(_ *kgo.Record, err error) {
+		errCh <- err
+	})
+	return <-errCh
+}
+
+func (s *auditKafkaSink) Close() error {
+	s.client.Close()
+	return nil
+}
+
+// auditCLSSink 把每条 auditRecord 上报到腾讯云日志服务（CLS）
+type auditCLSSink struct {
+	producer *cls.AsyncProducerClient
+	topicID  string
+}
+
+func newAuditCLSSink(endpoint, accessKeyID, accessKeySecret, topicID string) (*auditCLSSink, error) {
+	cfg := cls.GetDefaultAsyncProducerClientConfig()
+	cfg.Endpoint = endpoint
+	cfg.AccessKeyID = accessKeyID
+	cfg.AccessKeySecret = accessKeySecret
+
+	producer, err := cls.NewAsyncProducerClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cls client: %w", err)
+	}
+	producer.Start()
+	return &auditCLSSink{producer: producer, topicID: topicID}, nil
+}
+
+func (s *auditCLSSink) Emit(rec auditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	log := cls.NewCLSLog(time.Now().Unix(), map[string]string{
+		"action":  rec.Action,
+		"success": fmt.Sprintf("%t", rec.Success),
+		"caller":  rec.Caller,
+		"record":  string(data),
+	})
+	return s.producer.SendLog(s.topicID, log, nil)
+}
+
+func (s *auditCLSSink) Close() error {
+	return s.producer.Close(5000)
+}
+
+var (
+	globalAuditRecorder     *auditRecorder
+	globalAuditRecorderOnce sync.Once
+)
+
+// getAuditRecorder 返回进程级共享的 auditRecorder，所有 VU 复用同一组 Sink 连接/文件句柄，
+// 避免每个 VU 各自打开一份文件或各自建一条 Kafka/CLS 连接
+func getAuditRecorder(secretID, secretKey string) *auditRecorder {
+	globalAuditRecorderOnce.Do(func() {
+		globalAuditRecorder = newAuditRecorderFromEnv(secretID, secretKey)
+	})
+	return globalAuditRecorder
+}
+
+// newAuditRecorderFromEnv 根据环境变量构造 auditRecorder：AUDIT_ENABLED=true 时启用，
+// AUDIT_SINKS 以逗号分隔选择 file/kafka/cls（可多选），未启用时返回 nil
+func newAuditRecorderFromEnv(secretID, secretKey string) *auditRecorder {
+	if strings.TrimSpace(os.Getenv("AUDIT_ENABLED")) != "true" {
+		return nil
+	}
+
+	var sinks []auditSink
+	for _, name := range strings.Split(os.Getenv("AUDIT_SINKS"), ",") {
+		switch strings.TrimSpace(name) {
+		case "file":
+			path := strings.TrimSpace(os.Getenv("AUDIT_FILE_PATH"))
+			if path == "" {
+				path = "audit.jsonl"
+			}
+			sink, err := newAuditJSONLSink(path)
+			if err != nil {
+				log.Printf("failed to init audit file sink, skipping: %v", err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			brokers := strings.Split(strings.TrimSpace(os.Getenv("AUDIT_KAFKA_BROKERS")), ",")
+			topic := strings.TrimSpace(os.Getenv("AUDIT_KAFKA_TOPIC"))
+			sink, err := newAuditKafkaSink(brokers, topic)
+			if err != nil {
+				log.Printf("failed to init audit kafka sink, skipping: %v", err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		case "cls":
+			endpoint := strings.TrimSpace(os.Getenv("AUDIT_CLS_ENDPOINT"))
+			topicID := strings.TrimSpace(os.Getenv("AUDIT_CLS_TOPIC_ID"))
+			sink, err := newAuditCLSSink(endpoint, secretID, secretKey, topicID)
+			if err != nil {
+				log.Printf("failed to init audit cls sink, skipping: %v", err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		case "":
+			// AUDIT_SINKS 未设置时忽略
+		default:
+			log.Printf("unknown audit sink %q, skipping", name)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil
+	}
+	return &auditRecorder{sinks: sinks}
+}
+
+// callerID 返回当前 VU+iteration 的标识，供审计事件的 Caller 字段使用；
+// 在 init context（没有活跃迭代）下返回 "vu-unknown"
+func (m *AGS) callerID() string {
+	state := m.vu.State()
+	if state == nil {
+		return "vu-unknown"
+	}
+	return fmt.Sprintf("vu-%d/iter-%d", state.VUID, state.Iteration)
+}
+
+// marshalAuditParams 把任意 request 结构体转换为 map[string]any，供 Emit 的 Params 使用
+func marshalAuditParams(v any) map[string]any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// auditRequestID 从 AGS SDK 响应的 Response.RequestId 字段提取 RequestId，
+// 响应为 nil 或字段为 nil 时返回空字符串
+func auditRequestID(requestID *string) string {
+	if requestID == nil {
+		return ""
+	}
+	return *requestID
+}
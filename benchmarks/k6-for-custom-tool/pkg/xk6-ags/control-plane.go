@@ -3,6 +3,7 @@ package xk6ags
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"time"
 
 	ags "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/ags/v20250920"
@@ -31,6 +32,7 @@ func (m *AGS) GetAsyncStopResults() []*AsyncTaskResult[*ControlPlaneResponse] {
 func (m *AGS) StartSandboxInstance(params map[string]any) *ControlPlaneResponse {
 	start := time.Now()
 	resp := &ControlPlaneResponse{}
+	defer func() { getMetricsRecorder().Record("StartSandboxInstance", resp.Success, float64(resp.TimingMs)) }()
 
 	if m.client == nil {
 		resp.Error = "client not initialized"
@@ -55,6 +57,14 @@ func (m *AGS) StartSandboxInstance(params map[string]any) *ControlPlaneResponse
 	sdkResp, err := m.client.StartSandboxInstance(request)
 	resp.TimingMs = time.Since(start).Milliseconds()
 
+	if m.auditRecorder != nil {
+		requestID := ""
+		if sdkResp != nil && sdkResp.Response != nil {
+			requestID = auditRequestID(sdkResp.Response.RequestId)
+		}
+		m.auditRecorder.emit("StartSandboxInstance", m.callerID(), marshalAuditParams(request), start, requestID, err)
+	}
+
 	if err != nil {
 		resp.Error = err.Error()
 		if resp.Error == "" {
@@ -89,8 +99,14 @@ func (m *AGS) StartSandboxInstance(params map[string]any) *ControlPlaneResponse
 	return resp
 }
 
+// stopTaskPayload 是持久化到 Store 的异步停止任务数据，重启后据此重建停止函数
+type stopTaskPayload struct {
+	InstanceID string `json:"instance_id"`
+}
+
 // StartSandboxInstanceWithAsyncStop 启动沙箱实例，并在指定时间后自动停止（独立于迭代生命周期）
 // delaySeconds: 延迟停止的秒数，实际延迟会在 ±20% 范围内随机波动
+// 若执行器绑定了 Store，InstanceId 和预定停止时间会持久化，进程重启后仍会按时停止实例
 func (m *AGS) StartSandboxInstanceWithAsyncStop(params map[string]any, delaySeconds int) *ControlPlaneResponse {
 	if delaySeconds <= 0 {
 		return &ControlPlaneResponse{
@@ -114,8 +130,23 @@ func (m *AGS) StartSandboxInstanceWithAsyncStop(params map[string]any, delaySeco
 		return resp
 	}
 
+	// ±20% 抖动的实际延迟，与 AsyncTaskExecutor.Submit 保持一致，确保持久化的 runAfter 与真实调度时间相符
+	jitter := float64(delaySeconds) * 0.2
+	actualDelay := float64(delaySeconds) + (rand.Float64()*2-1)*jitter
+	if actualDelay < 0 {
+		actualDelay = 0
+	}
+	runAfter := time.Now().Add(time.Duration(actualDelay * float64(time.Second)))
+
+	payload, err := json.Marshal(stopTaskPayload{InstanceID: instanceId})
+	if err != nil {
+		resp.Success = false
+		resp.Error = fmt.Sprintf("failed to marshal stop task payload: %v", err)
+		return resp
+	}
+
 	// 提交异步停止任务
-	err := getAsyncStopExecutor().Submit(instanceId, delaySeconds, func() (*ControlPlaneResponse, error) {
+	err = getAsyncStopExecutor().SubmitDurable(instanceId, payload, runAfter, func() (*ControlPlaneResponse, error) {
 		return m.StopSandboxInstance(map[string]any{"InstanceId": instanceId}), nil
 	})
 
@@ -127,10 +158,27 @@ func (m *AGS) StartSandboxInstanceWithAsyncStop(params map[string]any, delaySeco
 	return resp
 }
 
+// rehydrateStopTask 根据持久化的 payload 重建 stop 任务的执行函数，供 RestoreDurable 在进程重启后调用
+func (m *AGS) rehydrateStopTask(payload []byte) (func() (*ControlPlaneResponse, error), error) {
+	var p stopTaskPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stop task payload: %w", err)
+	}
+	return func() (*ControlPlaneResponse, error) {
+		return m.StopSandboxInstance(map[string]any{"InstanceId": p.InstanceID}), nil
+	}, nil
+}
+
+// RestoreAsyncStop 从持久化存储恢复尚未完成的异步停止任务，应在进程启动时调用一次
+func (m *AGS) RestoreAsyncStop() error {
+	return getAsyncStopExecutor().RestoreDurable(m.rehydrateStopTask)
+}
+
 // StopSandboxInstance 停止沙箱实例
 func (m *AGS) StopSandboxInstance(params map[string]any) *ControlPlaneResponse {
 	start := time.Now()
 	resp := &ControlPlaneResponse{}
+	defer func() { getMetricsRecorder().Record("StopSandboxInstance", resp.Success, float64(resp.TimingMs)) }()
 
 	if m.client == nil {
 		resp.Error = "client not initialized"
@@ -155,6 +203,14 @@ func (m *AGS) StopSandboxInstance(params map[string]any) *ControlPlaneResponse {
 	sdkResp, err := m.client.StopSandboxInstance(request)
 	resp.TimingMs = time.Since(start).Milliseconds()
 
+	if m.auditRecorder != nil {
+		requestID := ""
+		if sdkResp != nil && sdkResp.Response != nil {
+			requestID = auditRequestID(sdkResp.Response.RequestId)
+		}
+		m.auditRecorder.emit("StopSandboxInstance", m.callerID(), marshalAuditParams(request), start, requestID, err)
+	}
+
 	if err != nil {
 		resp.Error = err.Error()
 		return resp
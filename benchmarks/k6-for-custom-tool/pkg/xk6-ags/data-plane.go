@@ -1,80 +1,85 @@
 package xk6ags
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
+
 	ags "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/ags/v20250920"
 )
 
 // ============================================
 // Token 管理
 // ============================================
-
-type tokenCache struct {
-	token     string
-	expiresAt time.Time
-}
+//
+// 实际的缓存/持久化逻辑由可插拔的 TokenStore 实现（见 token_store.go），
+// tokenManager 只负责在 store 未命中时向 AGS 拉取新 token，并用 singleflight
+// 合并同一实例的并发拉取请求。
 
 type tokenManager struct {
 	ags   *AGS
-	mu    sync.RWMutex
-	cache map[string]*tokenCache
+	store TokenStore
+	sf    singleflight.Group
 }
 
 func newTokenManager(ags *AGS) *tokenManager {
-	return &tokenManager{
-		ags:   ags,
-		cache: make(map[string]*tokenCache),
-	}
+	return newTokenManagerWithStore(ags, NewMemoryTokenStore())
+}
+
+func newTokenManagerWithStore(ags *AGS, store TokenStore) *tokenManager {
+	return &tokenManager{ags: ags, store: store}
 }
 
 func (tm *tokenManager) getToken(instanceID string) (string, error) {
-	tm.mu.RLock()
-	if c, ok := tm.cache[instanceID]; ok && time.Now().Add(30*time.Second).Before(c.expiresAt) {
-		token := c.token
-		tm.mu.RUnlock()
+	if token, expiresAt, ok, err := tm.store.Get(instanceID); err == nil && ok && time.Now().Add(30*time.Second).Before(expiresAt) {
 		return token, nil
 	}
-	tm.mu.RUnlock()
 
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
+	v, err, _ := tm.sf.Do(instanceID, func() (any, error) {
+		// double check：等待进入 singleflight 的过程中，另一个请求可能已经刷新了 token
+		if token, expiresAt, ok, err := tm.store.Get(instanceID); err == nil && ok && time.Now().Add(30*time.Second).Before(expiresAt) {
+			return token, nil
+		}
 
-	// double check
-	if c, ok := tm.cache[instanceID]; ok && time.Now().Add(30*time.Second).Before(c.expiresAt) {
-		return c.token, nil
-	}
+		request := ags.NewAcquireSandboxInstanceTokenRequest()
+		request.InstanceId = &instanceID
 
-	request := ags.NewAcquireSandboxInstanceTokenRequest()
-	request.InstanceId = &instanceID
+		response, err := tm.ags.client.AcquireSandboxInstanceToken(request)
+		if err != nil {
+			return "", fmt.Errorf("failed to acquire token: %w", err)
+		}
 
-	response, err := tm.ags.client.AcquireSandboxInstanceToken(request)
-	if err != nil {
-		return "", fmt.Errorf("failed to acquire token: %w", err)
-	}
+		if response.Response == nil || response.Response.Token == nil {
+			return "", fmt.Errorf("empty token response")
+		}
 
-	if response.Response == nil || response.Response.Token == nil {
-		return "", fmt.Errorf("empty token response")
-	}
+		token := *response.Response.Token
+		expiresAt := time.Now().Add(5 * time.Minute)
+		if response.Response.ExpiresAt != nil {
+			if t, err := time.Parse(time.RFC3339Nano, *response.Response.ExpiresAt); err == nil {
+				expiresAt = t
+			}
+		}
 
-	token := *response.Response.Token
-	expiresAt := time.Now().Add(5 * time.Minute)
-	if response.Response.ExpiresAt != nil {
-		if t, err := time.Parse(time.RFC3339Nano, *response.Response.ExpiresAt); err == nil {
-			expiresAt = t
+		if err := tm.store.Set(instanceID, token, expiresAt); err != nil {
+			return "", fmt.Errorf("failed to persist token: %w", err)
 		}
-	}
 
-	tm.cache[instanceID] = &tokenCache{token: token, expiresAt: expiresAt}
-	return token, nil
+		return token, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
 }
 
 // ============================================
@@ -83,12 +88,14 @@ func (tm *tokenManager) getToken(instanceID string) (string, error) {
 
 // Response HTTP 响应结构
 type Response struct {
-	Status   int               `json:"status"`
-	Body     string            `json:"body"`
-	Headers  map[string]string `json:"headers"`
-	Error    string            `json:"error,omitempty"`
-	RemoteIP string            `json:"remote_ip"`
-	TimingMs float64           `json:"timings_ms"`
+	Status       int               `json:"status"`
+	Body         string            `json:"body"`
+	Headers      map[string]string `json:"headers"`
+	Error        string            `json:"error,omitempty"`
+	RemoteIP     string            `json:"remote_ip"`
+	TimingMs     float64           `json:"timings_ms"`
+	Retries      int               `json:"retries,omitempty"`       // 本次调用除首次外额外尝试的次数
+	CircuitState string            `json:"circuit_state,omitempty"` // 调用结束时熔断器状态，未配置熔断器时为空
 }
 
 func (m *AGS) buildURL(instanceID, port, path string) (string, error) {
@@ -106,9 +113,105 @@ func (m *AGS) doRequest(method, instanceID, port, path, body string, headers map
 	return m.doRequestWithTimeout(method, instanceID, port, path, body, headers, 30*time.Second)
 }
 
+// doRequestWithTimeout 在 m.retryPolicy 配置的约束下执行一次数据面请求：限流器/熔断器
+// （若配置）按 instanceID 各自独立生效，见 circuitBreakerFor/rateLimiterFor；一个实例
+// 的失败或限流不会影响同一 VU 对其他实例的调用。限流器节流每一次尝试；熔断器在开路时
+// 直接短路失败；重试策略（若配置）决定 5xx/429 失败后是否重试，429 响应若带 Retry-After
+// 头则按其要求的时长退避（仍受 MaxBackoff 约束），否则按 backoffFor 计算的退避曲线重试。
+// 均未配置时行为与之前完全一致（单次请求，无重试）。
 func (m *AGS) doRequestWithTimeout(method, instanceID, port, path, body string, headers map[string]string, timeout time.Duration) *Response {
+	maxAttempts := 1
+	if m.retryPolicy != nil && m.retryPolicy.MaxAttempts > 0 {
+		maxAttempts = m.retryPolicy.MaxAttempts
+	}
+
+	circuitBreaker := m.circuitBreakerFor(instanceID)
+	rateLimiter := m.rateLimiterFor(instanceID)
+
+	var resp *Response
+	attempt := 1
+	for ; attempt <= maxAttempts; attempt++ {
+		if circuitBreaker != nil {
+			if allowed, state := circuitBreaker.Allow(); !allowed {
+				resp = &Response{Error: "circuit breaker open", CircuitState: string(state)}
+				break
+			}
+		}
+
+		if rateLimiter != nil {
+			_ = rateLimiter.Wait(context.Background())
+		}
+
+		resp = m.doRequestOnce(method, instanceID, port, path, body, headers, timeout)
+
+		// 429（限流）和 5xx 都当作可重试的失败：429 不是熔断器意义上的故障（服务本身是健康
+		// 的，只是要求调用方放慢），但对重试循环而言必须和 5xx 一样触发重试，否则请求会被
+		// 错误地当成最终结果返回
+		success := resp.Error == "" && resp.Status < 500 && resp.Status != http.StatusTooManyRequests
+		if circuitBreaker != nil {
+			circuitBreaker.RecordResult(resp.Error == "" && resp.Status < 500)
+			resp.CircuitState = string(circuitBreaker.State())
+		}
+
+		if success || m.retryPolicy == nil {
+			break
+		}
+
+		shouldRetry := m.retryPolicy.RetryIf == nil || m.retryPolicy.RetryIf(resp, nil)
+		if !shouldRetry || attempt >= maxAttempts {
+			break
+		}
+
+		backoff := m.retryPolicy.backoffFor(attempt)
+		// 429 响应若带 Retry-After，按服务端要求的时长等待（仍受 MaxBackoff 约束），
+		// 而不是完全按本地计算的退避曲线猜测
+		if resp.Status == http.StatusTooManyRequests {
+			if retryAfter, ok := parseRetryAfter(resp.Headers["Retry-After"]); ok {
+				backoff = retryAfter
+				if m.retryPolicy.MaxBackoff > 0 && backoff > m.retryPolicy.MaxBackoff {
+					backoff = m.retryPolicy.MaxBackoff
+				}
+			}
+		}
+		if backoff > 0 {
+			time.Sleep(backoff)
+		}
+	}
+
+	resp.Retries = attempt - 1
+	return resp
+}
+
+// parseRetryAfter 解析 Retry-After 响应头（RFC 7231）：可以是一个非负整数秒数，
+// 也可以是 HTTP-date，此时返回该时刻与当前时间的差值；无法解析时 ok 为 false
+func parseRetryAfter(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		until := time.Until(t)
+		if until < 0 {
+			until = 0
+		}
+		return until, true
+	}
+	return 0, false
+}
+
+// doRequestOnce 执行一次不带重试/熔断/限流的数据面请求
+func (m *AGS) doRequestOnce(method, instanceID, port, path, body string, headers map[string]string, timeout time.Duration) *Response {
 	start := time.Now()
 	resp := &Response{Headers: make(map[string]string)}
+	defer func() {
+		getMetricsRecorder().Record("DataPlaneRequest:"+method, resp.Error == "", time.Since(start).Seconds()*1000)
+	}()
 
 	token, err := m.tokenManager.getToken(instanceID)
 	if err != nil {
@@ -0,0 +1,399 @@
+package xk6ags
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// 分布式多实例压测编排
+// ============================================
+
+// CoordinationMode 描述多个实例上的压测任务如何协调启动
+type CoordinationMode string
+
+const (
+	CoordinationBarrier     CoordinationMode = "barrier"     // 所有实例就绪后同时开始
+	CoordinationStaggered   CoordinationMode = "staggered"   // 按 StaggerSec 依次错峰启动
+	CoordinationIndependent CoordinationMode = "independent" // 各实例立即独立启动，不做协调
+)
+
+// DistributedStressConfig 描述一批多实例压测任务
+type DistributedStressConfig struct {
+	InstanceIDs []string         `json:"instance_ids"` // 参与压测的实例 ID 列表
+	Port        string           `json:"port"`         // shell2http 端口，默认 8080
+	Mode        CoordinationMode `json:"mode"`         // 启动协调模式，默认 barrier
+	StaggerSec  int              `json:"stagger_sec"`  // Mode == staggered 时，相邻实例的启动间隔（秒），默认 5
+	Configs     []map[string]any `json:"configs"`      // 每个实例依次执行的 stress-ng 配置，含义同 RunAsyncStress 的 configs
+}
+
+func parseDistributedStressConfig(config map[string]any) (*DistributedStressConfig, error) {
+	cfg := &DistributedStressConfig{Port: "8080", Mode: CoordinationBarrier, StaggerSec: 5}
+	if config != nil {
+		configBytes, err := json.Marshal(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal config: %v", err)
+		}
+		if err := json.Unmarshal(configBytes, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+	}
+	if len(cfg.InstanceIDs) == 0 {
+		return nil, fmt.Errorf("instance_ids is required")
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = CoordinationBarrier
+	case CoordinationBarrier, CoordinationStaggered, CoordinationIndependent:
+	default:
+		return nil, fmt.Errorf("unknown coordination mode: %s", cfg.Mode)
+	}
+	if cfg.StaggerSec <= 0 {
+		cfg.StaggerSec = 5
+	}
+	return cfg, nil
+}
+
+// InstanceStressResult 单个实例的压测结果
+type InstanceStressResult struct {
+	InstanceID string              `json:"instance_id"`
+	Report     *Shell2HttpResponse `json:"report,omitempty"`
+	Metrics    []StressorMetrics   `json:"metrics,omitempty"` // 每一轮 stress-ng --metrics-brief 解析出的 per-stressor 数据，按轮次顺序累加
+	Error      string              `json:"error,omitempty"`
+	StartedAt  int64               `json:"started_at"`
+	EndedAt    int64               `json:"ended_at"`
+}
+
+// StressorMetrics 是 stress-ng --metrics-brief 输出中单个 stressor 的一行汇总数据
+type StressorMetrics struct {
+	Stressor                string  `json:"stressor"`
+	BogoOps                 float64 `json:"bogo_ops"`
+	RealTimeSec             float64 `json:"real_time_sec"`
+	UsrTimeSec              float64 `json:"usr_time_sec"`
+	SysTimeSec              float64 `json:"sys_time_sec"`
+	BogoOpsPerSecRealTime   float64 `json:"bogo_ops_per_sec_real_time"`
+	BogoOpsPerSecUsrSysTime float64 `json:"bogo_ops_per_sec_usr_sys_time"`
+}
+
+// stressNGMetricsLineRe 匹配 "stress-ng: metrc: [<pid>] <stressor> <bogo ops> <real time> <usr time> <sys time> <bogo ops/s real time> <bogo ops/s usr+sys time>"
+// 形式的数据行，表头行（没有数值的 "stressor"/"(secs)" 等）不会匹配
+var stressNGMetricsLineRe = regexp.MustCompile(`^stress-ng:\s*metrc:\s*\[\d+\]\s+(\S+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s+([\d.]+)\s*$`)
+
+// parseStressNGMetrics 从 --metrics-brief 的输出中解析每个 stressor 一行的 bogo-ops 数据，
+// 无法识别的行（info 行、表头行等）直接忽略
+func parseStressNGMetrics(output string) []StressorMetrics {
+	var metrics []StressorMetrics
+	for _, line := range strings.Split(output, "\n") {
+		fields := stressNGMetricsLineRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if fields == nil {
+			continue
+		}
+		metrics = append(metrics, StressorMetrics{
+			Stressor:                fields[1],
+			BogoOps:                 parseFloatOrZero(fields[2]),
+			RealTimeSec:             parseFloatOrZero(fields[3]),
+			UsrTimeSec:              parseFloatOrZero(fields[4]),
+			SysTimeSec:              parseFloatOrZero(fields[5]),
+			BogoOpsPerSecRealTime:   parseFloatOrZero(fields[6]),
+			BogoOpsPerSecUsrSysTime: parseFloatOrZero(fields[7]),
+		})
+	}
+	return metrics
+}
+
+func parseFloatOrZero(s string) float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// StressorSummary 是某个 stressor 的 bogo-ops/s（real time）在一批实例间的跨实例统计，
+// 用于判断不同沙箱实例之间的性能方差
+type StressorSummary struct {
+	Stressor string  `json:"stressor"`
+	Samples  int     `json:"samples"` // 参与统计的样本数（实例数 × 该 stressor 出现的轮次数）
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Mean     float64 `json:"mean"`
+	StdDev   float64 `json:"stddev"`
+}
+
+// summarizeStressorMetrics 按 stressor 名称汇总所有实例全部轮次的 bogo-ops/s（real time）
+func summarizeStressorMetrics(results []*InstanceStressResult) []*StressorSummary {
+	samples := make(map[string][]float64)
+	var order []string
+	for _, r := range results {
+		if r == nil {
+			continue
+		}
+		for _, m := range r.Metrics {
+			if _, ok := samples[m.Stressor]; !ok {
+				order = append(order, m.Stressor)
+			}
+			samples[m.Stressor] = append(samples[m.Stressor], m.BogoOpsPerSecRealTime)
+		}
+	}
+
+	summary := make([]*StressorSummary, 0, len(order))
+	for _, name := range order {
+		values := samples[name]
+		summary = append(summary, &StressorSummary{
+			Stressor: name,
+			Samples:  len(values),
+			Min:      minFloat(values),
+			Max:      maxFloat(values),
+			Mean:     meanFloat(values),
+			StdDev:   stddevFloat(values),
+		})
+	}
+	return summary
+}
+
+func minFloat(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func maxFloat(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+func meanFloat(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func stddevFloat(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := meanFloat(values)
+	var sq float64
+	for _, v := range values {
+		d := v - mean
+		sq += d * d
+	}
+	return math.Sqrt(sq / float64(len(values)))
+}
+
+// BatchReport 是一批分布式压测的汇总结果，随任务推进逐步填充，Done 为 true 时表示全部实例已完成
+type BatchReport struct {
+	BatchID   string                  `json:"batch_id"`
+	Total     int                     `json:"total"`
+	Succeeded int                     `json:"succeeded"`
+	Failed    int                     `json:"failed"`
+	Done      bool                    `json:"done"`
+	Results   []*InstanceStressResult `json:"results,omitempty"`
+	Summary   []*StressorSummary      `json:"summary,omitempty"` // 按 stressor 汇总的跨实例 bogo-ops/s 统计
+}
+
+type distributedStressRegistry struct {
+	mu      sync.RWMutex
+	batches map[string]*BatchReport
+}
+
+var batchRegistry = &distributedStressRegistry{batches: make(map[string]*BatchReport)}
+
+func newBatchID() string {
+	return fmt.Sprintf("batch-%d-%d", time.Now().UnixNano(), rand.Intn(1_000_000))
+}
+
+// RunDistributedStress 在多个实例上编排一批 stress-ng 压测，立即返回 batchID，结果通过 GetBatchReport 异步获取
+// config 字段: instance_ids (必填), port, mode (barrier/staggered/independent), stagger_sec, configs
+func (m *AGS) RunDistributedStress(config map[string]any) (string, error) {
+	cfg, err := parseDistributedStressConfig(config)
+	if err != nil {
+		return "", err
+	}
+
+	batchID := newBatchID()
+	batchRegistry.mu.Lock()
+	batchRegistry.batches[batchID] = &BatchReport{BatchID: batchID, Total: len(cfg.InstanceIDs)}
+	batchRegistry.mu.Unlock()
+
+	go m.runDistributedStress(batchID, cfg)
+
+	return batchID, nil
+}
+
+// roundBarrier 是一个可重复使用的栅栏：CoordinationBarrier 模式下，所有实例在每一轮配置
+// 开始前都要先到齐，再一起进入该轮，而不仅仅是整批任务的第一轮
+type roundBarrier struct {
+	mu      sync.Mutex
+	target  int // 仍需到齐的实例数，某个实例提前退出（如配置解析失败）时递减
+	arrived int
+	gen     chan struct{}
+}
+
+func newRoundBarrier(n int) *roundBarrier {
+	return &roundBarrier{target: n, gen: make(chan struct{})}
+}
+
+// wait 阻塞直到仍在场的所有实例都到达同一轮
+func (b *roundBarrier) wait() {
+	b.mu.Lock()
+	if b.target <= 0 {
+		b.mu.Unlock()
+		return
+	}
+	ch := b.gen
+	b.arrived++
+	if b.arrived >= b.target {
+		b.arrived = 0
+		b.gen = make(chan struct{})
+		close(ch)
+		b.mu.Unlock()
+		return
+	}
+	b.mu.Unlock()
+	<-ch
+}
+
+// abandon 在某个实例提前退出压测（如配置解析失败）时调用，避免其余实例在后续轮次永久等待它
+func (b *roundBarrier) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.target <= 0 {
+		return
+	}
+	b.target--
+	if b.target > 0 && b.arrived >= b.target {
+		arrived := b.arrived
+		b.arrived = 0
+		ch := b.gen
+		b.gen = make(chan struct{})
+		if arrived > 0 {
+			close(ch)
+		}
+	}
+}
+
+// runDistributedStress 按 cfg.Mode 协调各实例的启动时机，等待全部完成后写回汇总结果
+func (m *AGS) runDistributedStress(batchID string, cfg *DistributedStressConfig) {
+	results := make([]*InstanceStressResult, len(cfg.InstanceIDs))
+
+	var barrier *roundBarrier
+	if cfg.Mode == CoordinationBarrier {
+		barrier = newRoundBarrier(len(cfg.InstanceIDs))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(cfg.InstanceIDs))
+	for i, instanceID := range cfg.InstanceIDs {
+		go func(i int, instanceID string) {
+			defer wg.Done()
+
+			if cfg.Mode == CoordinationStaggered {
+				time.Sleep(time.Duration(i*cfg.StaggerSec) * time.Second)
+			}
+
+			results[i] = m.runInstanceStress(instanceID, cfg, barrier)
+		}(i, instanceID)
+	}
+	wg.Wait()
+
+	succeeded, failed := 0, 0
+	for _, r := range results {
+		if r.Error == "" {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+
+	batchRegistry.mu.Lock()
+	if report, ok := batchRegistry.batches[batchID]; ok {
+		report.Succeeded = succeeded
+		report.Failed = failed
+		report.Results = results
+		report.Summary = summarizeStressorMetrics(results)
+		report.Done = true
+	}
+	batchRegistry.mu.Unlock()
+}
+
+// runInstanceStress 在单个实例上依次执行 cfg.Configs 中的每一轮 stress-ng 压测；barrier 非 nil 时
+// （CoordinationBarrier 模式），每一轮开始前都会等待其他实例到达同一轮，而不只是第一轮
+func (m *AGS) runInstanceStress(instanceID string, cfg *DistributedStressConfig, barrier *roundBarrier) *InstanceStressResult {
+	result := &InstanceStressResult{InstanceID: instanceID, StartedAt: time.Now().UnixMilli()}
+	defer func() { result.EndedAt = time.Now().UnixMilli() }()
+
+	var stressConfigs []*StressConfig
+	if len(cfg.Configs) == 0 {
+		stressConfigs = append(stressConfigs, applyConfigJitter(DefaultStressConfig()))
+	} else {
+		for _, c := range cfg.Configs {
+			parsed, err := parseStressConfig(c)
+			if err != nil {
+				result.Error = err.Error()
+				if barrier != nil {
+					barrier.abandon()
+				}
+				return result
+			}
+			stressConfigs = append(stressConfigs, applyConfigJitter(parsed))
+		}
+	}
+
+	var lastResp *Shell2HttpResponse
+	for _, sc := range stressConfigs {
+		if barrier != nil {
+			barrier.wait()
+		}
+
+		command := buildStressNGCommand(sc)
+		timeout := sc.Timeout + 10
+		lastResp = m.ExecShell2Http(instanceID, cfg.Port, command, timeout)
+		if lastResp.Error != "" {
+			result.Error = lastResp.Error
+			if barrier != nil {
+				barrier.abandon()
+			}
+			break
+		}
+		result.Metrics = append(result.Metrics, parseStressNGMetrics(lastResp.Output)...)
+	}
+
+	result.Report = lastResp
+	return result
+}
+
+// GetBatchReport 获取一批分布式压测的当前汇总结果，Done 为 false 时表示仍有实例在执行
+func (m *AGS) GetBatchReport(batchID string) (*BatchReport, error) {
+	batchRegistry.mu.RLock()
+	defer batchRegistry.mu.RUnlock()
+
+	report, ok := batchRegistry.batches[batchID]
+	if !ok {
+		return nil, fmt.Errorf("unknown batch id: %s", batchID)
+	}
+
+	reportCopy := *report
+	return &reportCopy, nil
+}
@@ -0,0 +1,277 @@
+package xk6ags
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================
+// HTTP 负载生成
+// ============================================
+
+// LoadConfig 描述一次 HTTP 压测的并发度和持续时间
+type LoadConfig struct {
+	Port              string            `json:"port"`                // shell2http/业务端口，默认 8080
+	Method            string            `json:"method"`              // HTTP 方法，默认 GET
+	Path              string            `json:"path"`                // 请求路径，默认 /
+	Body              string            `json:"body"`                // 请求体
+	Headers           map[string]string `json:"headers"`             // 额外请求头
+	Concurrency       int               `json:"concurrency"`         // 并发 worker 数，默认 1
+	DurationSec       int               `json:"duration_sec"`        // 持续时间（秒），默认 10
+	MaxRequests       int               `json:"max_requests"`        // 总请求数上限，0 表示不限制，仅受 DurationSec 约束
+	RequestTimeoutSec int               `json:"request_timeout_sec"` // 单个请求超时（秒），默认 30
+	Verify            *VerifyRule       `json:"verify,omitempty"`    // 业务层成功判定规则，为空时退化为“无传输错误且状态码 < 400”
+}
+
+// VerifyRule 描述如何判定一次请求在业务层面是否成功，各字段独立生效、全部满足才算通过，
+// 零值字段不参与判定
+type VerifyRule struct {
+	StatusBelow int    `json:"status_below"` // 状态码需小于该值才算通过，<= 0 时按 400 处理
+	Contains    string `json:"contains"`     // 响应体需包含该子串，空值不检查
+	JSONPath    string `json:"json_path"`    // 用 "." 分隔的字段路径（如 "data.status"），仅支持 map 层级查找
+	JSONEquals  any    `json:"json_equals"`  // JSONPath 命中的值需与此相等（反射比较），为 nil 时只检查路径存在
+}
+
+// verifyResponse 按 rule 判定一次响应是否业务意义上成功；rule 为 nil 时只检查传输层错误和状态码。
+// 返回值 ok 表示通过，未通过时 reason 给出具体原因，用于 LoadReport.VerifyFailures 按原因分类计数
+func verifyResponse(resp *Response, rule *VerifyRule) (ok bool, reason string) {
+	if resp.Error != "" {
+		return false, "transport_error"
+	}
+
+	statusBelow := 400
+	if rule != nil && rule.StatusBelow > 0 {
+		statusBelow = rule.StatusBelow
+	}
+	if resp.Status >= statusBelow {
+		return false, fmt.Sprintf("status_%d", resp.Status)
+	}
+
+	if rule == nil {
+		return true, ""
+	}
+
+	if rule.Contains != "" && !strings.Contains(resp.Body, rule.Contains) {
+		return false, "contains_mismatch"
+	}
+
+	if rule.JSONPath != "" {
+		var parsed any
+		if err := json.Unmarshal([]byte(resp.Body), &parsed); err != nil {
+			return false, "json_parse_error"
+		}
+		actual, found := lookupJSONPath(parsed, rule.JSONPath)
+		if !found {
+			return false, "json_path_not_found"
+		}
+		if rule.JSONEquals != nil && !reflect.DeepEqual(actual, rule.JSONEquals) {
+			return false, "json_path_mismatch"
+		}
+	}
+
+	return true, ""
+}
+
+// lookupJSONPath 在 json.Unmarshal 得到的 value（map[string]any 嵌套结构）中按 "." 分隔的 path 查找字段
+func lookupJSONPath(value any, path string) (any, bool) {
+	current := value
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// DefaultLoadConfig 默认 HTTP 压测配置
+func DefaultLoadConfig() *LoadConfig {
+	return &LoadConfig{
+		Port:              "8080",
+		Method:            "GET",
+		Path:              "/",
+		Concurrency:       1,
+		DurationSec:       10,
+		RequestTimeoutSec: 30,
+	}
+}
+
+func parseLoadConfig(config map[string]any) (*LoadConfig, error) {
+	cfg := DefaultLoadConfig()
+	if config != nil {
+		configBytes, err := json.Marshal(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal config: %v", err)
+		}
+		if err := json.Unmarshal(configBytes, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %v", err)
+		}
+	}
+	if cfg.Port == "" {
+		cfg.Port = "8080"
+	}
+	if cfg.Method == "" {
+		cfg.Method = "GET"
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.DurationSec <= 0 {
+		cfg.DurationSec = 10
+	}
+	if cfg.RequestTimeoutSec <= 0 {
+		cfg.RequestTimeoutSec = 30
+	}
+	return cfg, nil
+}
+
+// LoadReport 是一次 HTTP 压测的汇总结果
+type LoadReport struct {
+	Total          int64            `json:"total"`
+	Success        int64            `json:"success"`
+	Failed         int64            `json:"failed"`
+	DurationMs     int64            `json:"duration_ms"`
+	RPS            float64          `json:"rps"`
+	MinMs          float64          `json:"min_ms"`
+	MaxMs          float64          `json:"max_ms"`
+	AvgMs          float64          `json:"avg_ms"`
+	P50Ms          float64          `json:"p50_ms"`
+	P90Ms          float64          `json:"p90_ms"`
+	P95Ms          float64          `json:"p95_ms"`
+	P99Ms          float64          `json:"p99_ms"`
+	StatusCodes    map[int]int64    `json:"status_codes"`
+	BytesReceived  int64            `json:"bytes_received"`            // 全部响应体的累计字节数
+	VerifyFailures map[string]int64 `json:"verify_failures,omitempty"` // 失败原因 -> 次数，见 verifyResponse
+}
+
+// RunHTTPLoad 对单个沙箱实例的数据面端口发起固定并发度、固定时长的 HTTP 压测，同步返回汇总结果
+// config 可选字段: port, method, path, body, headers, concurrency, duration_sec, max_requests, request_timeout_sec
+func (m *AGS) RunHTTPLoad(instanceID string, config map[string]any) (*LoadReport, error) {
+	cfg, err := parseLoadConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return runHTTPLoad(m, instanceID, cfg), nil
+}
+
+// runHTTPLoad 是 RunHTTPLoad 和 RunAsyncHTTPLoad 共用的压测逻辑
+func runHTTPLoad(m *AGS, instanceID string, cfg *LoadConfig) *LoadReport {
+	start := time.Now()
+	deadline := start.Add(time.Duration(cfg.DurationSec) * time.Second)
+	timeout := time.Duration(cfg.RequestTimeoutSec) * time.Second
+
+	var (
+		total, success, failed int64
+		requestsIssued         int64
+		bytesReceived          int64
+		mu                     sync.Mutex
+		latencies              []float64
+		statusCodes            = make(map[int]int64)
+		verifyFailures         = make(map[string]int64)
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(cfg.Concurrency)
+	for i := 0; i < cfg.Concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				if cfg.MaxRequests > 0 && atomic.AddInt64(&requestsIssued, 1) > int64(cfg.MaxRequests) {
+					return
+				}
+
+				resp := m.doRequestWithTimeout(cfg.Method, instanceID, cfg.Port, cfg.Path, cfg.Body, cfg.Headers, timeout)
+				atomic.AddInt64(&total, 1)
+				atomic.AddInt64(&bytesReceived, int64(len(resp.Body)))
+				ok, reason := verifyResponse(resp, cfg.Verify)
+				if ok {
+					atomic.AddInt64(&success, 1)
+				} else {
+					atomic.AddInt64(&failed, 1)
+				}
+
+				mu.Lock()
+				latencies = append(latencies, resp.TimingMs)
+				if resp.Status > 0 {
+					statusCodes[resp.Status]++
+				}
+				if !ok {
+					verifyFailures[reason]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	sort.Float64s(latencies)
+
+	report := &LoadReport{
+		Total:         total,
+		Success:       success,
+		Failed:        failed,
+		DurationMs:    elapsed.Milliseconds(),
+		StatusCodes:   statusCodes,
+		BytesReceived: bytesReceived,
+	}
+	if len(verifyFailures) > 0 {
+		report.VerifyFailures = verifyFailures
+	}
+	if elapsed.Seconds() > 0 {
+		report.RPS = float64(total) / elapsed.Seconds()
+	}
+	if len(latencies) > 0 {
+		report.MinMs = latencies[0]
+		report.MaxMs = latencies[len(latencies)-1]
+
+		sum := 0.0
+		for _, v := range latencies {
+			sum += v
+		}
+		report.AvgMs = sum / float64(len(latencies))
+
+		report.P50Ms = percentile(latencies, 0.50)
+		report.P90Ms = percentile(latencies, 0.90)
+		report.P95Ms = percentile(latencies, 0.95)
+		report.P99Ms = percentile(latencies, 0.99)
+	}
+	return report
+}
+
+// RunAsyncHTTPLoad 异步发起一次 HTTP 压测，delaySeconds 支持 ±20% 抖动，结果通过 GetAsyncHTTPLoadResults 取回
+func (m *AGS) RunAsyncHTTPLoad(instanceID string, delaySeconds int, config map[string]any) error {
+	cfg, err := parseLoadConfig(config)
+	if err != nil {
+		return err
+	}
+	return getAsyncHTTPLoadExecutor().Submit(instanceID, delaySeconds, func() (*LoadReport, error) {
+		return runHTTPLoad(m, instanceID, cfg), nil
+	})
+}
+
+// GetAsyncHTTPLoadPendingCount 获取待执行的异步压测任务数量
+func (m *AGS) GetAsyncHTTPLoadPendingCount() int64 {
+	return getAsyncHTTPLoadExecutor().GetPendingCount()
+}
+
+// GetAsyncHTTPLoadResults 获取所有已完成的异步压测结果
+func (m *AGS) GetAsyncHTTPLoadResults() []*AsyncTaskResult[*LoadReport] {
+	return getAsyncHTTPLoadExecutor().GetResults()
+}
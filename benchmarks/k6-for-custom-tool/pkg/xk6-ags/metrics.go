@@ -0,0 +1,343 @@
+package xk6ags
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================================
+// 滚动窗口延迟统计
+// ============================================
+//
+// 每次控制面/数据面调用结束时记录一条 (operation, success, durationMs) 样本。
+// 样本按多档分辨率落入环形缓冲区的固定桶中：1s 桶覆盖最近 5 分钟，10s 桶覆盖最近 1 小时，
+// 1m 桶覆盖最近 1 天。旧桶写入新数据时直接覆盖，因此内存占用恒定，可以安全放在调用的热路径上。
+
+// resolution 描述一档分辨率的桶宽和桶数
+type resolution struct {
+	name        string
+	bucketWidth time.Duration
+	bucketCount int
+}
+
+var metricsResolutions = []resolution{
+	{name: "1s", bucketWidth: time.Second, bucketCount: 300},       // 最近 5 分钟
+	{name: "10s", bucketWidth: 10 * time.Second, bucketCount: 360}, // 最近 1 小时
+	{name: "1m", bucketWidth: time.Minute, bucketCount: 1440},      // 最近 1 天
+}
+
+// maxSamplesPerBucket 每个桶最多保留的耗时采样数，超出后按水库采样丢弃，用于近似分位数
+const maxSamplesPerBucket = 128
+
+// metricsBucket 单个时间桶的聚合数据
+type metricsBucket struct {
+	slot     int64 // 桶起始时间（按 bucketWidth 对齐后的槽位编号），用于判断桶是否已被新周期覆盖
+	count    int64
+	errCount int64
+	samples  []float64 // 耗时采样（毫秒）
+}
+
+// metricsRing 固定桶数的环形缓冲区
+type metricsRing struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets []metricsBucket
+}
+
+func newMetricsRing(res resolution) *metricsRing {
+	return &metricsRing{width: res.bucketWidth, buckets: make([]metricsBucket, res.bucketCount)}
+}
+
+func (r *metricsRing) slotFor(ts time.Time) int64 {
+	width := int64(r.width / time.Second)
+	if width < 1 {
+		width = 1
+	}
+	return ts.Unix() / width
+}
+
+// record 把一次调用落入对应的桶，桶如果已经是一个新周期（slot 变化）则先清空
+func (r *metricsRing) record(ts time.Time, durationMs float64, success bool) {
+	slot := r.slotFor(ts)
+	idx := int(slot % int64(len(r.buckets)))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := &r.buckets[idx]
+	if b.slot != slot {
+		*b = metricsBucket{slot: slot}
+	}
+
+	b.count++
+	if !success {
+		b.errCount++
+	}
+
+	if len(b.samples) < maxSamplesPerBucket {
+		b.samples = append(b.samples, durationMs)
+	} else if n := rand.Intn(int(b.count)); n < maxSamplesPerBucket {
+		b.samples[n] = durationMs
+	}
+}
+
+// snapshot 导出窗口内仍然有效（未被覆盖周期判定为过期）的桶
+func (r *metricsRing) snapshot(now time.Time) []BucketStat {
+	nowSlot := r.slotFor(now)
+	oldestSlot := nowSlot - int64(len(r.buckets)) + 1
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make([]BucketStat, 0, len(r.buckets))
+	for i := range r.buckets {
+		b := &r.buckets[i]
+		if b.count == 0 || b.slot < oldestSlot || b.slot > nowSlot {
+			continue
+		}
+		stats = append(stats, bucketStatFrom(b, int64(r.width/time.Second)))
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Timestamp < stats[j].Timestamp })
+	return stats
+}
+
+func bucketStatFrom(b *metricsBucket, bucketWidthSeconds int64) BucketStat {
+	samples := append([]float64(nil), b.samples...)
+	sort.Float64s(samples)
+
+	stat := BucketStat{
+		Timestamp: b.slot * bucketWidthSeconds,
+		Count:     b.count,
+		P50:       percentile(samples, 0.50),
+		P95:       percentile(samples, 0.95),
+		P99:       percentile(samples, 0.99),
+	}
+	if b.count > 0 {
+		stat.ErrorRate = float64(b.errCount) / float64(b.count)
+	}
+	return stat
+}
+
+// percentile 对已排序的 samples 做最近邻插值，samples 为空时返回 0
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Round(p * float64(len(sorted)-1)))
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// BucketStat 是单个时间桶的聚合统计
+type BucketStat struct {
+	Timestamp int64   `json:"timestamp"` // 桶起始时间（unix 秒）
+	Count     int64   `json:"count"`
+	ErrorRate float64 `json:"error_rate"`
+	P50       float64 `json:"p50_ms"`
+	P95       float64 `json:"p95_ms"`
+	P99       float64 `json:"p99_ms"`
+}
+
+// ResolutionSnapshot 是某个操作在一档分辨率下的时间序列
+type ResolutionSnapshot struct {
+	Resolution string       `json:"resolution"`
+	Buckets    []BucketStat `json:"buckets"`
+}
+
+// OperationSnapshot 是某个操作在全部分辨率下的时间序列
+type OperationSnapshot struct {
+	Operation string               `json:"operation"`
+	Series    []ResolutionSnapshot `json:"series"`
+}
+
+// MetricsSnapshot 是一次完整的指标导出，可直接序列化为 JSON 供 k6 summary 或 HTTP 端点使用
+type MetricsSnapshot struct {
+	GeneratedAt int64               `json:"generated_at"`
+	Operations  []OperationSnapshot `json:"operations"`
+}
+
+// operationMetrics 一个操作（按 operation 名 + 维度）的多分辨率环形缓冲区
+type operationMetrics struct {
+	rings []*metricsRing
+}
+
+func newOperationMetrics() *operationMetrics {
+	rings := make([]*metricsRing, len(metricsResolutions))
+	for i, res := range metricsResolutions {
+		rings[i] = newMetricsRing(res)
+	}
+	return &operationMetrics{rings: rings}
+}
+
+// metricsRecorder 记录所有操作的调用延迟，一个进程内只需一个实例
+type metricsRecorder struct {
+	mu  sync.Mutex
+	ops map[string]*operationMetrics
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{ops: make(map[string]*operationMetrics)}
+}
+
+func (r *metricsRecorder) getOrCreate(operation string) *operationMetrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, ok := r.ops[operation]
+	if !ok {
+		op = newOperationMetrics()
+		r.ops[operation] = op
+	}
+	return op
+}
+
+// Record 记录一次调用：operation 为接口名（如 StartSandboxInstance），success 表示是否成功，durationMs 为耗时
+func (r *metricsRecorder) Record(operation string, success bool, durationMs float64) {
+	now := time.Now()
+	op := r.getOrCreate(operation)
+	for _, ring := range op.rings {
+		ring.record(now, durationMs, success)
+	}
+}
+
+// Snapshot 导出全部操作、全部分辨率的当前时间序列
+func (r *metricsRecorder) Snapshot() *MetricsSnapshot {
+	now := time.Now()
+
+	r.mu.Lock()
+	operations := make([]string, 0, len(r.ops))
+	for name := range r.ops {
+		operations = append(operations, name)
+	}
+	r.mu.Unlock()
+	sort.Strings(operations)
+
+	snapshot := &MetricsSnapshot{GeneratedAt: now.Unix()}
+	for _, name := range operations {
+		op := r.getOrCreate(name)
+		opSnapshot := OperationSnapshot{Operation: name}
+		for i, res := range metricsResolutions {
+			opSnapshot.Series = append(opSnapshot.Series, ResolutionSnapshot{
+				Resolution: res.name,
+				Buckets:    op.rings[i].snapshot(now),
+			})
+		}
+		snapshot.Operations = append(snapshot.Operations, opSnapshot)
+	}
+	return snapshot
+}
+
+var (
+	globalMetricsRecorder     *metricsRecorder
+	globalMetricsRecorderOnce sync.Once
+)
+
+func getMetricsRecorder() *metricsRecorder {
+	globalMetricsRecorderOnce.Do(func() {
+		globalMetricsRecorder = newMetricsRecorder()
+	})
+	return globalMetricsRecorder
+}
+
+// GetMetricsSnapshot 导出所有已记录操作的滚动窗口延迟统计，可直接用于 k6 summary 或写入文件
+func (m *AGS) GetMetricsSnapshot() *MetricsSnapshot {
+	return getMetricsRecorder().Snapshot()
+}
+
+// ============================================
+// 进程级可观测性自动启动
+// ============================================
+//
+// k6 会为每个 VU 调用一次 NewModuleInstance，若逐个 VU 都根据环境变量启动 /metrics 端点或
+// 云监控 pusher 会导致端口冲突/重复上报，因此这里用 sync.Once 保证整个进程只启动一次，
+// 和 getMetricsRecorder() 共享同一个单例模式。
+
+var autoObservabilityOnce sync.Once
+
+// maybeStartAutoObservability 根据环境变量启动进程级的 /metrics 端点和云监控 pusher：
+// METRICS_ADDR 非空时启动 /metrics 端点；MONITOR_ENABLED=true 时启动云监控 pusher。
+func maybeStartAutoObservability(secretID, secretKey, region string) {
+	autoObservabilityOnce.Do(func() {
+		if addr := strings.TrimSpace(os.Getenv("METRICS_ADDR")); addr != "" {
+			if _, err := startMetricsServerOn(addr); err != nil {
+				log.Printf("failed to auto-start /metrics endpoint on %s: %v", addr, err)
+			}
+		}
+
+		if pusher := newMonitorPusherFromEnv(secretID, secretKey, region); pusher != nil {
+			pusher.start()
+		}
+	})
+}
+
+// StartMetricsServer 启动一个只读的 Prometheus 风格 /metrics HTTP 端点，供外部抓取
+// 返回的 func() 用于关闭端点；addr 为空时默认监听 :9090
+func (m *AGS) StartMetricsServer(addr string) (func(), error) {
+	return startMetricsServerOn(addr)
+}
+
+// startMetricsServerOn 是 StartMetricsServer 的无接收者版本，供 maybeStartAutoObservability
+// 在尚无 *AGS 实例（构造过程中）时复用
+func startMetricsServerOn(addr string) (func(), error) {
+	if addr == "" {
+		addr = ":9090"
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writePrometheusExposition(w, getMetricsRecorder().Snapshot())
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return func() { _ = server.Close() }, nil
+}
+
+// writePrometheusExposition 把最细粒度（1s）分辨率的最新桶渲染成 Prometheus 文本暴露格式，
+// 并附带异步任务队列的堆积量，便于监控"控制面调用延迟升高是否伴随异步任务堆积"这类关联问题。
+func writePrometheusExposition(w http.ResponseWriter, snapshot *MetricsSnapshot) {
+	fmt.Fprintln(w, "# HELP ags_call_duration_ms_p99 AGS SDK call latency p99 in the most recent bucket")
+	fmt.Fprintln(w, "# TYPE ags_call_duration_ms_p99 gauge")
+	for _, op := range snapshot.Operations {
+		if len(op.Series) == 0 || len(op.Series[0].Buckets) == 0 {
+			continue
+		}
+		latest := op.Series[0].Buckets[len(op.Series[0].Buckets)-1]
+		fmt.Fprintf(w, "ags_call_duration_ms_p99{operation=%q} %f\n", op.Operation, latest.P99)
+		fmt.Fprintf(w, "ags_call_count{operation=%q} %d\n", op.Operation, latest.Count)
+		fmt.Fprintf(w, "ags_call_error_rate{operation=%q} %f\n", op.Operation, latest.ErrorRate)
+	}
+
+	fmt.Fprintln(w, "# HELP ags_async_stop_pending Number of StartSandboxInstanceWithAsyncStop tasks not yet completed")
+	fmt.Fprintln(w, "# TYPE ags_async_stop_pending gauge")
+	fmt.Fprintf(w, "ags_async_stop_pending %d\n", getAsyncStopExecutor().GetPendingCount())
+
+	fmt.Fprintln(w, "# HELP ags_async_stress_pending Number of RunAsyncStress tasks not yet completed")
+	fmt.Fprintln(w, "# TYPE ags_async_stress_pending gauge")
+	fmt.Fprintf(w, "ags_async_stress_pending %d\n", getAsyncStressExecutor().GetPendingCount())
+}
@@ -0,0 +1,190 @@
+package xk6ags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tcerrors "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	"golang.org/x/time/rate"
+)
+
+// monitorServiceName/monitorAPIVersion 标识云监控自定义指标写入接口（PutMonitorData），
+// 这个 SDK 版本没有生成强类型的 Request/Response，因此和 goscripts/yunapi/monitor 一样，
+// 直接用 common.Client 拼 CommonRequest 调用
+const (
+	monitorServiceName = "monitor"
+	monitorAPIVersion  = "2018-07-24"
+)
+
+// ============================================
+// 云监控自定义指标推送
+// ============================================
+//
+// 在 GetMetricsSnapshot/StartMetricsServer 之外，额外提供一个把关键指标定期批量推送到
+// 腾讯云云监控的 pusher，命名空间默认为 QCE/AGS_PRECACHE（与 Precacher 共用，便于同一面板
+// 对照控制面/数据面延迟与镜像预热进度）。
+
+// defaultMonitorNamespace 是云监控自定义指标默认使用的命名空间
+const defaultMonitorNamespace = "QCE/AGS_PRECACHE"
+
+// monitorPusher 按固定周期把最新的控制面调用延迟与异步任务堆积量推送到云监控
+type monitorPusher struct {
+	commonClient *common.Client
+	limiter      *rate.Limiter
+	namespace    string
+	interval     time.Duration
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// newMonitorPusherFromEnv 根据环境变量构造 pusher：
+// MONITOR_ENABLED=true 时启用，MONITOR_NAMESPACE 覆盖默认命名空间，
+// MONITOR_PUSH_SECONDS 覆盖默认 60s 推送周期
+func newMonitorPusherFromEnv(secretID, secretKey, region string) *monitorPusher {
+	if strings.TrimSpace(os.Getenv("MONITOR_ENABLED")) != "true" {
+		return nil
+	}
+
+	cred := common.NewCredential(secretID, secretKey)
+	var commonClient common.Client
+	commonClient.Init(region).WithCredential(cred).WithProfile(profile.NewClientProfile())
+
+	namespace := strings.TrimSpace(os.Getenv("MONITOR_NAMESPACE"))
+	if namespace == "" {
+		namespace = defaultMonitorNamespace
+	}
+
+	interval := 60 * time.Second
+	if v := strings.TrimSpace(os.Getenv("MONITOR_PUSH_SECONDS")); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			interval = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return &monitorPusher{
+		commonClient: &commonClient,
+		limiter:      rate.NewLimiter(rate.Limit(5), 5),
+		namespace:    namespace,
+		interval:     interval,
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+}
+
+// start 启动后台推送循环，每个周期把 GetMetricsSnapshot 中最新的 p99/pending 指标上报
+func (p *monitorPusher) start() {
+	go func() {
+		defer close(p.doneCh)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.pushOnce()
+			case <-p.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (p *monitorPusher) stop() {
+	close(p.stopCh)
+	<-p.doneCh
+}
+
+// monitorMetricDatum 对应 PutMonitorData 接口里的 Metrics[].MetricDatum 结构
+type monitorMetricDatum struct {
+	MetricName string    `json:"MetricName"`
+	Timestamps []int64   `json:"Timestamps"`
+	Values     []float64 `json:"Values"`
+}
+
+func (p *monitorPusher) pushOnce() {
+	now := time.Now()
+	snapshot := getMetricsRecorder().Snapshot()
+
+	var datums []monitorMetricDatum
+	addPoint := func(name string, value float64) {
+		datums = append(datums, monitorMetricDatum{
+			MetricName: name,
+			Timestamps: []int64{now.Unix()},
+			Values:     []float64{value},
+		})
+	}
+
+	for _, op := range snapshot.Operations {
+		if len(op.Series) == 0 || len(op.Series[0].Buckets) == 0 {
+			continue
+		}
+		latest := op.Series[0].Buckets[len(op.Series[0].Buckets)-1]
+		addPoint(fmt.Sprintf("call_duration_ms_p99_%s", op.Operation), latest.P99)
+		addPoint(fmt.Sprintf("call_error_rate_%s", op.Operation), latest.ErrorRate)
+	}
+
+	addPoint("async_stop_pending", float64(getAsyncStopExecutor().GetPendingCount()))
+	addPoint("async_stress_pending", float64(getAsyncStressExecutor().GetPendingCount()))
+
+	if len(datums) == 0 {
+		return
+	}
+
+	if err := p.limiter.Wait(context.Background()); err != nil {
+		return
+	}
+
+	params := map[string]any{
+		"Namespace": p.namespace,
+		"Metrics":   datums,
+	}
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		log.Printf("failed to marshal cloud monitor params: %v", err)
+		return
+	}
+
+	request := tchttp.NewCommonRequest(monitorServiceName, monitorAPIVersion, "PutMonitorData")
+	if err := request.SetActionParameters(paramsBytes); err != nil {
+		log.Printf("failed to set cloud monitor params: %v", err)
+		return
+	}
+
+	response := tchttp.NewCommonResponse()
+	if err := p.commonClient.Send(request, response); err != nil {
+		log.Printf("failed to push metrics to cloud monitor: %v", err)
+		return
+	}
+
+	var commonResp struct {
+		Response struct {
+			Error *struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"Error,omitempty"`
+			RequestId string `json:"RequestId"`
+		} `json:"Response"`
+	}
+	if err := json.Unmarshal(response.GetBody(), &commonResp); err != nil {
+		log.Printf("failed to parse cloud monitor response: %v", err)
+		return
+	}
+	if commonResp.Response.Error != nil {
+		log.Printf("failed to push metrics to cloud monitor: %s", tcerrors.NewTencentCloudSDKError(
+			commonResp.Response.Error.Code,
+			commonResp.Response.Error.Message,
+			commonResp.Response.RequestId,
+		))
+	}
+}
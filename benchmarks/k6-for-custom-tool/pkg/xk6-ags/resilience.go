@@ -0,0 +1,188 @@
+package xk6ags
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ============================================
+// 数据面请求的重试 / 熔断 / 限流
+// ============================================
+
+// AGSOption 配置 AGS 实例的数据面请求行为，用于 NewAGSWithTokenStore
+type AGSOption func(*AGS)
+
+// WithRetryPolicy 为数据面请求（Get/Post/Put/Delete/Patch 等）配置重试策略；
+// policy.RetryIf 的 error 参数恒为 nil，判断是否重试请直接检查 *Response.Error/Status
+func WithRetryPolicy(policy RetryPolicy[*Response]) AGSOption {
+	return func(a *AGS) {
+		a.retryPolicy = &policy
+	}
+}
+
+// WithCircuitBreaker 为每个 instanceID 各配置一个独立的熔断器，cb 作为模板提供
+// failureThreshold/resetTimeout，不直接共享状态——某个沙箱实例连续失败熔断后，
+// 不会影响同一 VU 对其他实例的调用
+func WithCircuitBreaker(cb *CircuitBreaker) AGSOption {
+	return func(a *AGS) {
+		a.circuitBreakerTemplate = cb
+	}
+}
+
+// WithRateLimit 为每个 instanceID 各配置一个独立的令牌桶限流器，用于限制单个调用方
+// 自身的 QPS，互不影响；ratePerSecond <= 0 时不限流
+func WithRateLimit(ratePerSecond float64, burst int) AGSOption {
+	return func(a *AGS) {
+		if ratePerSecond <= 0 {
+			return
+		}
+		if burst <= 0 {
+			burst = 1
+		}
+		a.rateLimit = rate.Limit(ratePerSecond)
+		a.rateBurst = burst
+	}
+}
+
+// CircuitState 是熔断器的当前状态
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    // 正常放行
+	CircuitOpen     CircuitState = "open"      // 熔断中，直接拒绝
+	CircuitHalfOpen CircuitState = "half_open" // 探测中，放行一个请求判断是否恢复
+)
+
+// CircuitBreaker 是一个简单的单路熔断器：连续失败达到阈值后进入 Open，
+// 冷却时间过后进入 HalfOpen 放行一个探测请求，探测成功则恢复 Closed，失败则重新 Open。
+type CircuitBreaker struct {
+	mu sync.Mutex
+
+	failureThreshold int
+	resetTimeout     time.Duration
+
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    bool
+}
+
+// NewCircuitBreaker 创建熔断器：failureThreshold 为连续失败多少次后熔断，resetTimeout 为熔断后多久进入探测
+func NewCircuitBreaker(failureThreshold int, resetTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 1
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		resetTimeout:     resetTimeout,
+		state:            CircuitClosed,
+	}
+}
+
+// Allow 判断当前是否允许发起一次请求，并返回判断时刻的熔断器状态
+func (cb *CircuitBreaker) Allow() (bool, CircuitState) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == CircuitOpen {
+		if time.Since(cb.openedAt) < cb.resetTimeout {
+			return false, cb.state
+		}
+		cb.state = CircuitHalfOpen
+		cb.halfOpenInFlight = false
+	}
+
+	if cb.state == CircuitHalfOpen {
+		if cb.halfOpenInFlight {
+			return false, cb.state
+		}
+		cb.halfOpenInFlight = true
+	}
+
+	return true, cb.state
+}
+
+// RecordResult 记录一次请求的成功/失败，驱动熔断器状态迁移
+func (cb *CircuitBreaker) RecordResult(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if success {
+		cb.consecutiveFailures = 0
+		cb.halfOpenInFlight = false
+		if cb.state == CircuitHalfOpen {
+			cb.state = CircuitClosed
+		}
+		return
+	}
+
+	cb.halfOpenInFlight = false
+	if cb.state == CircuitHalfOpen {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+		return
+	}
+
+	cb.consecutiveFailures++
+	if cb.consecutiveFailures >= cb.failureThreshold {
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	}
+}
+
+// State 返回熔断器当前状态
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// clone 按同样的 failureThreshold/resetTimeout 配置创建一个状态独立的新熔断器，
+// 供每个 instanceID 各持有自己的一份
+func (cb *CircuitBreaker) clone() *CircuitBreaker {
+	return NewCircuitBreaker(cb.failureThreshold, cb.resetTimeout)
+}
+
+// circuitBreakerFor 返回 instanceID 对应的熔断器，未配置 WithCircuitBreaker 时返回 nil；
+// 首次访问某个 instanceID 时从模板克隆一份独立状态的熔断器
+func (a *AGS) circuitBreakerFor(instanceID string) *CircuitBreaker {
+	if a.circuitBreakerTemplate == nil {
+		return nil
+	}
+
+	a.circuitBreakersMu.Lock()
+	defer a.circuitBreakersMu.Unlock()
+
+	cb, ok := a.circuitBreakers[instanceID]
+	if !ok {
+		cb = a.circuitBreakerTemplate.clone()
+		if a.circuitBreakers == nil {
+			a.circuitBreakers = make(map[string]*CircuitBreaker)
+		}
+		a.circuitBreakers[instanceID] = cb
+	}
+	return cb
+}
+
+// rateLimiterFor 返回 instanceID 对应的令牌桶限流器，未配置 WithRateLimit 时返回 nil；
+// 首次访问某个 instanceID 时按配置的 rate/burst 创建一个独立的限流器
+func (a *AGS) rateLimiterFor(instanceID string) *rate.Limiter {
+	if a.rateLimit <= 0 {
+		return nil
+	}
+
+	a.rateLimitersMu.Lock()
+	defer a.rateLimitersMu.Unlock()
+
+	rl, ok := a.rateLimiters[instanceID]
+	if !ok {
+		rl = rate.NewLimiter(a.rateLimit, a.rateBurst)
+		if a.rateLimiters == nil {
+			a.rateLimiters = make(map[string]*rate.Limiter)
+		}
+		a.rateLimiters[instanceID] = rl
+	}
+	return rl
+}
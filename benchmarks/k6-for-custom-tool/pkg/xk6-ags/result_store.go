@@ -0,0 +1,456 @@
+package xk6ags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+// maxRunIndexConflictRetries 是 COSResultStore 在遇到并发写入冲突（ETag/If-Match 条件不满足）
+// 时读取-合并-重试的最大次数
+const maxRunIndexConflictRetries = 5
+
+// errRunIndexConflict 表示一次条件写入因对象已被其他实例并发修改而失败，调用方应重新读取后重试
+var errRunIndexConflict = errors.New("run index write conflict")
+
+// ============================================
+// 异步结果持久化存储
+// ============================================
+//
+// GetAsyncStopResults/GetAsyncStressResults 把结果放在各自 AsyncTaskExecutor 的内存缓冲区里，
+// 单个 VU 重启或跨实例分布式压测时都无法汇总。FlushAsyncResults 把当前已完成的结果写入一个
+// 可插拔的 ResultStore：每个任务的结果是一个以 run-id/task-id 为 key 的 JSON 对象，run 下
+// 再维护一个 index 对象记录该 run 已落盘的全部 task-id，供 ListAsyncResultRuns 读取。
+
+// ResultEnvelope 是一个异步任务结果落盘时的信封
+type ResultEnvelope struct {
+	RunID     string          `json:"run_id"`
+	Category  string          `json:"category"` // "stop" 或 "stress"
+	TaskID    string          `json:"task_id"`
+	Result    json.RawMessage `json:"result"`
+	FlushedAt int64           `json:"flushed_at"`
+}
+
+// RunIndex 记录某个 run 下已落盘的全部任务 ID
+type RunIndex struct {
+	RunID   string   `json:"run_id"`
+	TaskIDs []string `json:"task_ids"`
+}
+
+// ResultStore 是 FlushAsyncResults 的可插拔落盘后端
+type ResultStore interface {
+	// PutResult 写入一个任务的结果信封
+	PutResult(env ResultEnvelope) error
+	// AppendRunIndexTaskIDs 把 taskIDs 合并进某个 run 的任务索引并落盘；分布式压测下多个实例
+	// 可能并发调用同一个 runID，实现必须自己保证这是一次原子的“读取现状-合并-写回”，不能
+	// 直接覆盖写入整个索引，否则后写入者会静默丢失先写入者刚落盘的 task_id
+	AppendRunIndexTaskIDs(runID string, taskIDs []string) error
+	// GetRunIndex 读取某个 run 的任务索引
+	GetRunIndex(runID string) (*RunIndex, error)
+	// ListRuns 列出已知的全部 run ID
+	ListRuns() ([]string, error)
+}
+
+// MemoryResultStore 纯内存实现，进程重启后状态丢失，是未设置 AGS_RESULT_COS_BUCKET 时的默认行为
+type MemoryResultStore struct {
+	mu      sync.Mutex
+	results map[string]ResultEnvelope
+	runs    map[string]*RunIndex
+	order   []string
+}
+
+// NewMemoryResultStore 创建内存结果存储
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{
+		results: make(map[string]ResultEnvelope),
+		runs:    make(map[string]*RunIndex),
+	}
+}
+
+func (s *MemoryResultStore) PutResult(env ResultEnvelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[resultKey(env.RunID, env.Category, env.TaskID)] = env
+	return nil
+}
+
+func (s *MemoryResultStore) AppendRunIndexTaskIDs(runID string, taskIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx, ok := s.runs[runID]
+	if !ok {
+		idx = &RunIndex{RunID: runID}
+		s.runs[runID] = idx
+		s.order = append(s.order, runID)
+	}
+	idx.TaskIDs = append(idx.TaskIDs, taskIDs...)
+	return nil
+}
+
+func (s *MemoryResultStore) GetRunIndex(runID string) (*RunIndex, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	idx, ok := s.runs[runID]
+	if !ok {
+		return nil, fmt.Errorf("未知的 run: %s", runID)
+	}
+	return idx, nil
+}
+
+func (s *MemoryResultStore) ListRuns() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]string, len(s.order))
+	copy(runs, s.order)
+	return runs, nil
+}
+
+func resultKey(runID, category, taskID string) string {
+	return runID + "/" + category + "/" + taskID
+}
+
+// COSResultStore 把结果写入腾讯云 COS，使多个分布式 k6 实例的 FlushAsyncResults 可以
+// 汇总到同一个 bucket/prefix 下，效仿 terraform 的 cos backend 用 COS 做共享状态存储
+type COSResultStore struct {
+	client *cos.Client
+	prefix string
+}
+
+// newCOSResultStoreFromEnv 根据环境变量构造 COS 结果存储：AGS_RESULT_COS_BUCKET 未设置时
+// 返回 nil，表示调用方应退回 MemoryResultStore；AGS_RESULT_COS_PREFIX 为对象 key 的公共前缀
+func newCOSResultStoreFromEnv(secretID, secretKey string) (*COSResultStore, error) {
+	bucketURL := strings.TrimSpace(os.Getenv("AGS_RESULT_COS_BUCKET"))
+	if bucketURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, fmt.Errorf("解析 AGS_RESULT_COS_BUCKET 失败: %w", err)
+	}
+
+	client := cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
+		Transport: &cos.AuthorizationTransport{SecretID: secretID, SecretKey: secretKey},
+	})
+
+	prefix := strings.Trim(strings.TrimSpace(os.Getenv("AGS_RESULT_COS_PREFIX")), "/")
+
+	return &COSResultStore{client: client, prefix: prefix}, nil
+}
+
+func (s *COSResultStore) objectKey(parts ...string) string {
+	all := parts
+	if s.prefix != "" {
+		all = append([]string{s.prefix}, parts...)
+	}
+	return strings.Join(all, "/")
+}
+
+func (s *COSResultStore) PutResult(env ResultEnvelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return fmt.Errorf("序列化结果信封失败: %w", err)
+	}
+
+	key := s.objectKey(env.RunID, env.Category, env.TaskID+".json")
+	if _, err := s.client.Object.Put(context.Background(), key, bytes.NewReader(data), nil); err != nil {
+		return fmt.Errorf("写入 COS 对象失败: key=%s: %w", key, err)
+	}
+	return nil
+}
+
+// AppendRunIndexTaskIDs 把 taskIDs 合并进 run 索引并写回 COS。多个分布式实例可能并发刷新
+// 同一个 run，这里用 ETag 条件写入代替裸覆盖：每次先读取当前索引及其 ETag，合并 taskIDs 后
+// 带上 If-Match（索引尚不存在时带 If-None-Match: *）写回，若写入时对象已被别的实例抢先改过
+// （412 Precondition Failed）就重新读取最新状态再试，而不是互相覆盖
+func (s *COSResultStore) AppendRunIndexTaskIDs(runID string, taskIDs []string) error {
+	if len(taskIDs) == 0 {
+		return nil
+	}
+
+	for attempt := 0; attempt < maxRunIndexConflictRetries; attempt++ {
+		idx, etag, exists, err := s.getRunIndexWithETag(runID)
+		if err != nil {
+			return err
+		}
+		idx.TaskIDs = append(idx.TaskIDs, taskIDs...)
+
+		if err := s.putRunIndexConditional(*idx, etag, exists); err != nil {
+			if errors.Is(err, errRunIndexConflict) {
+				continue
+			}
+			return err
+		}
+		return s.appendKnownRun(runID)
+	}
+	return fmt.Errorf("更新 COS run 索引失败: run=%s: 并发写入冲突次数过多", runID)
+}
+
+// getRunIndexWithETag 读取某个 run 的索引及其当前 ETag；run 尚不存在时 exists 为 false
+func (s *COSResultStore) getRunIndexWithETag(runID string) (idx *RunIndex, etag string, exists bool, err error) {
+	key := s.objectKey(runID, "index.json")
+	resp, err := s.client.Object.Get(context.Background(), key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return &RunIndex{RunID: runID}, "", false, nil
+		}
+		return nil, "", false, fmt.Errorf("读取 COS run 索引失败: key=%s: %w", key, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var parsed RunIndex
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, "", false, fmt.Errorf("解析 COS run 索引失败: %w", err)
+	}
+	return &parsed, resp.Header.Get("ETag"), true, nil
+}
+
+// putRunIndexConditional 写入 run 索引，exists 为 true 时要求 ETag 匹配（If-Match），
+// 为 false 时要求对象尚不存在（If-None-Match: *）；条件不满足时返回 errRunIndexConflict
+func (s *COSResultStore) putRunIndexConditional(idx RunIndex, etag string, exists bool) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("序列化 run 索引失败: %w", err)
+	}
+
+	key := s.objectKey(idx.RunID, "index.json")
+	if _, err := s.client.Object.Put(context.Background(), key, bytes.NewReader(data), conditionalPutOptions(etag, exists)); err != nil {
+		if isCOSPreconditionFailed(err) {
+			return errRunIndexConflict
+		}
+		return fmt.Errorf("写入 COS run 索引失败: key=%s: %w", key, err)
+	}
+	return nil
+}
+
+// conditionalPutOptions 构造带条件写入头的 ObjectPutOptions：exists 为 true 时要求 ETag 匹配
+// （防止覆盖掉别人刚写入的版本），为 false 时要求对象尚不存在（防止并发创建互相覆盖）
+func conditionalPutOptions(etag string, exists bool) *cos.ObjectPutOptions {
+	header := &http.Header{}
+	if exists {
+		header.Set("If-Match", etag)
+	} else {
+		header.Set("If-None-Match", "*")
+	}
+	return &cos.ObjectPutOptions{ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{XOptionHeader: header}}
+}
+
+// isCOSPreconditionFailed 判断 err 是否是 COS 对条件写入头返回的 412 Precondition Failed
+func isCOSPreconditionFailed(err error) bool {
+	var errResp *cos.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil {
+		return errResp.Response.StatusCode == http.StatusPreconditionFailed
+	}
+	return false
+}
+
+func (s *COSResultStore) GetRunIndex(runID string) (*RunIndex, error) {
+	key := s.objectKey(runID, "index.json")
+	resp, err := s.client.Object.Get(context.Background(), key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("读取 COS run 索引失败: key=%s: %w", key, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var idx RunIndex
+	if err := json.NewDecoder(resp.Body).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("解析 COS run 索引失败: %w", err)
+	}
+	return &idx, nil
+}
+
+func (s *COSResultStore) ListRuns() ([]string, error) {
+	key := s.objectKey("runs.json")
+	resp, err := s.client.Object.Get(context.Background(), key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取 COS run 列表失败: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var runs []string
+	if err := json.NewDecoder(resp.Body).Decode(&runs); err != nil {
+		return nil, fmt.Errorf("解析 COS run 列表失败: %w", err)
+	}
+	return runs, nil
+}
+
+// appendKnownRun 把 runID 追加到 runs.json 列表里（若尚未存在），供 ListRuns 枚举全部 run。
+// 和 AppendRunIndexTaskIDs 一样用 ETag 条件写入 + 重试，避免并发实例各自追加不同 runID 时
+// 后写入者覆盖掉先写入者刚加进去的那个
+func (s *COSResultStore) appendKnownRun(runID string) error {
+	key := s.objectKey("runs.json")
+
+	for attempt := 0; attempt < maxRunIndexConflictRetries; attempt++ {
+		runs, etag, exists, err := s.getKnownRunsWithETag()
+		if err != nil {
+			return err
+		}
+		for _, r := range runs {
+			if r == runID {
+				return nil
+			}
+		}
+		runs = append(runs, runID)
+
+		data, err := json.Marshal(runs)
+		if err != nil {
+			return fmt.Errorf("序列化 run 列表失败: %w", err)
+		}
+
+		_, err = s.client.Object.Put(context.Background(), key, bytes.NewReader(data), conditionalPutOptions(etag, exists))
+		if err == nil {
+			return nil
+		}
+		if !isCOSPreconditionFailed(err) {
+			return fmt.Errorf("写入 COS run 列表失败: %w", err)
+		}
+		// 被并发写入抢先，重新读取最新列表再试
+	}
+	return fmt.Errorf("更新 COS run 列表失败: run=%s: 并发写入冲突次数过多", runID)
+}
+
+// getKnownRunsWithETag 读取 runs.json 及其当前 ETag；对象尚不存在时 exists 为 false
+func (s *COSResultStore) getKnownRunsWithETag() (runs []string, etag string, exists bool, err error) {
+	key := s.objectKey("runs.json")
+	resp, err := s.client.Object.Get(context.Background(), key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return nil, "", false, nil
+		}
+		return nil, "", false, fmt.Errorf("读取 COS run 列表失败: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if err := json.NewDecoder(resp.Body).Decode(&runs); err != nil {
+		return nil, "", false, fmt.Errorf("解析 COS run 列表失败: %w", err)
+	}
+	return runs, resp.Header.Get("ETag"), true, nil
+}
+
+// ============================================
+// 全局单例：结果存储 + 当前 run-id
+// ============================================
+
+var (
+	resultStore      ResultStore
+	resultStoreOnce  sync.Once
+	currentRunID     string
+	currentRunIDOnce sync.Once
+)
+
+// getResultStore 按 AGS_RESULT_COS_BUCKET 是否设置选择结果存储后端
+func getResultStore() ResultStore {
+	resultStoreOnce.Do(func() {
+		secretID := strings.TrimSpace(os.Getenv("TENCENTCLOUD_SECRET_ID"))
+		secretKey := strings.TrimSpace(os.Getenv("TENCENTCLOUD_SECRET_KEY"))
+
+		cosStore, err := newCOSResultStoreFromEnv(secretID, secretKey)
+		if err != nil {
+			panic(fmt.Sprintf("failed to init COS result store: %v", err))
+		}
+		if cosStore != nil {
+			resultStore = cosStore
+			return
+		}
+
+		resultStore = NewMemoryResultStore()
+	})
+	return resultStore
+}
+
+// getCurrentRunID 返回当前进程的 run-id：优先使用 AGS_RUN_ID（分布式压测下由编排方统一下发，
+// 使各实例的 FlushAsyncResults 落到同一个 run 下），否则退化为基于启动时间生成的本地 run-id
+func getCurrentRunID() string {
+	currentRunIDOnce.Do(func() {
+		currentRunID = strings.TrimSpace(os.Getenv("AGS_RUN_ID"))
+		if currentRunID == "" {
+			currentRunID = fmt.Sprintf("run-%d", time.Now().UnixNano())
+		}
+	})
+	return currentRunID
+}
+
+// flushResults 把一个执行器的已完成结果序列化成 ResultEnvelope 并写入 store，返回成功写入的 task-id
+func flushResults[T any](store ResultStore, runID, category string, results []*AsyncTaskResult[T]) ([]string, error) {
+	var taskIDs []string
+	for _, r := range results {
+		payload, err := json.Marshal(r)
+		if err != nil {
+			return taskIDs, fmt.Errorf("序列化 %s 结果失败: task=%s: %w", category, r.TaskID, err)
+		}
+
+		env := ResultEnvelope{
+			RunID:     runID,
+			Category:  category,
+			TaskID:    r.TaskID,
+			Result:    payload,
+			FlushedAt: time.Now().UnixMilli(),
+		}
+		if err := store.PutResult(env); err != nil {
+			return taskIDs, fmt.Errorf("写入 %s 结果失败: task=%s: %w", category, r.TaskID, err)
+		}
+		taskIDs = append(taskIDs, r.TaskID)
+	}
+	return taskIDs, nil
+}
+
+// FlushAsyncResults 把当前已完成但尚未取走的异步 stop/stress 结果写入 ResultStore，并更新
+// 该 run 的任务索引，返回本次写入的任务数
+func (m *AGS) FlushAsyncResults() (int, error) {
+	store := getResultStore()
+	runID := getCurrentRunID()
+
+	stopIDs, err := flushResults(store, runID, "stop", getAsyncStopExecutor().GetResults())
+	if err != nil {
+		return 0, err
+	}
+	stressIDs, err := flushResults(store, runID, "stress", getAsyncStressExecutor().GetResults())
+	if err != nil {
+		return len(stopIDs), err
+	}
+
+	flushedIDs := append(stopIDs, stressIDs...)
+	if len(flushedIDs) == 0 {
+		return 0, nil
+	}
+
+	if err := store.AppendRunIndexTaskIDs(runID, flushedIDs); err != nil {
+		return len(flushedIDs), fmt.Errorf("更新 run 索引失败: %w", err)
+	}
+
+	return len(flushedIDs), nil
+}
+
+// ListAsyncResultRuns 查询 ResultStore：runID 为空时返回已知的全部 run ID，
+// 否则返回该 run 下已落盘的任务索引
+func (m *AGS) ListAsyncResultRuns(runID string) (any, error) {
+	store := getResultStore()
+
+	if runID == "" {
+		runs, err := store.ListRuns()
+		if err != nil {
+			return nil, fmt.Errorf("查询 run 列表失败: %w", err)
+		}
+		return runs, nil
+	}
+
+	idx, err := store.GetRunIndex(runID)
+	if err != nil {
+		return nil, fmt.Errorf("查询 run 索引失败: run=%s: %w", runID, err)
+	}
+	return idx, nil
+}
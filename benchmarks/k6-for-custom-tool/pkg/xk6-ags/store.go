@@ -0,0 +1,217 @@
+package xk6ags
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// TaskState 持久化任务的生命周期状态
+type TaskState int
+
+const (
+	TaskTodo TaskState = iota
+	TaskPending
+	TaskDone
+	TaskFailed
+)
+
+func (s TaskState) String() string {
+	switch s {
+	case TaskTodo:
+		return "Todo"
+	case TaskPending:
+		return "Pending"
+	case TaskDone:
+		return "Done"
+	case TaskFailed:
+		return "Failed"
+	default:
+		return "Unknown"
+	}
+}
+
+// Task 持久化任务记录
+type Task struct {
+	ID       string
+	Payload  []byte
+	RunAfter time.Time
+	State    TaskState
+	Attempts int
+	Result   []byte
+	Error    string
+}
+
+// Store 是 AsyncTaskExecutor 可插拔的持久化队列，用于进程崩溃后恢复未完成的任务
+type Store interface {
+	// Enqueue 登记一个新任务，状态重置为 Todo
+	Enqueue(taskID string, payload []byte, runAfter time.Time) error
+	// Claim 取出全部仍处于 Todo 状态的任务（包括 RunAfter 尚未到期的），并将其状态置为 Pending；
+	// MemoryStore/FileStore 不按 now 过滤
+	Claim(now time.Time) ([]Task, error)
+	// MarkDone 记录任务的最终结果，成功时 taskErr 为 nil
+	MarkDone(taskID string, result []byte, taskErr error) error
+	// Snapshot 导出当前全部任务状态
+	Snapshot() (io.Reader, error)
+	// Restore 从快照恢复任务状态
+	Restore(r io.Reader) error
+}
+
+// MemoryStore 纯内存实现，进程重启后状态丢失，等价于引入 Store 之前的行为
+type MemoryStore struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+}
+
+// NewMemoryStore 创建内存任务存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tasks: make(map[string]*Task)}
+}
+
+func (s *MemoryStore) Enqueue(taskID string, payload []byte, runAfter time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[taskID] = &Task{ID: taskID, Payload: payload, RunAfter: runAfter, State: TaskTodo}
+	return nil
+}
+
+func (s *MemoryStore) Claim(now time.Time) ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var claimed []Task
+	for _, t := range s.tasks {
+		if t.State != TaskTodo {
+			continue
+		}
+		t.State = TaskPending
+		t.Attempts++
+		claimed = append(claimed, *t)
+	}
+	return claimed, nil
+}
+
+func (s *MemoryStore) MarkDone(taskID string, result []byte, taskErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tasks[taskID]
+	if !ok {
+		return fmt.Errorf("unknown task: %s", taskID)
+	}
+	t.Result = result
+	if taskErr != nil {
+		t.State = TaskFailed
+		t.Error = taskErr.Error()
+	} else {
+		t.State = TaskDone
+	}
+	return nil
+}
+
+func (s *MemoryStore) Snapshot() (io.Reader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return encodeSnapshot(s.tasks)
+}
+
+func (s *MemoryStore) Restore(r io.Reader) error {
+	tasks, err := decodeSnapshot(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = tasks
+	return nil
+}
+
+// FileStore 在 MemoryStore 基础上把 Enqueue/MarkDone 的变更落盘，快照格式为 gob + gzip；
+// Claim 引起的状态变化（Pending、尝试次数）只保存在内存中，不写回磁盘
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+// NewFileStore 创建文件持久化的任务存储，path 不存在时视为空任务集
+func NewFileStore(path string) *FileStore {
+	return &FileStore{MemoryStore: NewMemoryStore(), path: path}
+}
+
+// Load 从磁盘加载快照
+func (s *FileStore) Load() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("打开快照文件失败: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	return s.Restore(f)
+}
+
+// Flush 把当前任务集写回磁盘
+func (s *FileStore) Flush() error {
+	r, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(s.path)
+	if err != nil {
+		return fmt.Errorf("创建快照文件失败: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("写入快照文件失败: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Enqueue(taskID string, payload []byte, runAfter time.Time) error {
+	if err := s.MemoryStore.Enqueue(taskID, payload, runAfter); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func (s *FileStore) MarkDone(taskID string, result []byte, taskErr error) error {
+	if err := s.MemoryStore.MarkDone(taskID, result, taskErr); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func encodeSnapshot(tasks map[string]*Task) (io.Reader, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := gob.NewEncoder(gz).Encode(tasks); err != nil {
+		return nil, fmt.Errorf("编码快照失败: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("压缩快照失败: %w", err)
+	}
+	return &buf, nil
+}
+
+func decodeSnapshot(r io.Reader) (map[string]*Task, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("解压快照失败: %w", err)
+	}
+	defer gz.Close() //nolint:errcheck
+
+	tasks := make(map[string]*Task)
+	if err := gob.NewDecoder(gz).Decode(&tasks); err != nil {
+		return nil, fmt.Errorf("解码快照失败: %w", err)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,134 @@
+package xk6ags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenStore 是 tokenManager 的沙箱 token 缓存后端，便于在单进程（MemoryTokenStore）
+// 和多进程共享（RedisTokenStore）之间切换，而不改变 getToken 的调用方式。
+type TokenStore interface {
+	// Get 返回 instanceID 对应的 token 及其过期时间；ok 为 false 表示未命中
+	Get(instanceID string) (token string, expiresAt time.Time, ok bool, err error)
+	// Set 写入/刷新 instanceID 对应的 token
+	Set(instanceID, token string, expiresAt time.Time) error
+	// Delete 移除 instanceID 对应的 token，用于主动失效一个已吊销/过期的 token
+	Delete(instanceID string) error
+}
+
+// ============================================
+// MemoryTokenStore：单进程内存缓存，NewModuleInstance 默认使用
+// ============================================
+
+type tokenCache struct {
+	token     string
+	expiresAt time.Time
+}
+
+// MemoryTokenStore 是进程内的 TokenStore 实现，各 VU 共享同一份缓存
+type MemoryTokenStore struct {
+	mu    sync.RWMutex
+	cache map[string]*tokenCache
+}
+
+// NewMemoryTokenStore 创建进程内 token 缓存
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{cache: make(map[string]*tokenCache)}
+}
+
+func (s *MemoryTokenStore) Get(instanceID string) (string, time.Time, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.cache[instanceID]
+	if !ok {
+		return "", time.Time{}, false, nil
+	}
+	return c.token, c.expiresAt, true, nil
+}
+
+func (s *MemoryTokenStore) Set(instanceID, token string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cache[instanceID] = &tokenCache{token: token, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(instanceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.cache, instanceID)
+	return nil
+}
+
+// ============================================
+// RedisTokenStore：跨进程共享缓存，用于多个 k6 runner 实例共享同一批 token
+// ============================================
+
+// redisTokenEntry 是写入 Redis 的 token 条目
+type redisTokenEntry struct {
+	Token       string `json:"token"`
+	ExpiresAtMs int64  `json:"expires_at_ms"`
+}
+
+// RedisTokenStore 把 token 存储在 Redis 中，供多个 xk6 进程共享，减少 AcquireSandboxInstanceToken 调用次数
+type RedisTokenStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisTokenStore 创建 Redis 后端的 TokenStore，prefix 用于和其他业务共用的 Redis 实例做 key 隔离
+func NewRedisTokenStore(client *redis.Client, prefix string) *RedisTokenStore {
+	return &RedisTokenStore{client: client, prefix: prefix}
+}
+
+func (s *RedisTokenStore) key(instanceID string) string {
+	return s.prefix + instanceID
+}
+
+func (s *RedisTokenStore) Get(instanceID string) (string, time.Time, bool, error) {
+	val, err := s.client.Get(context.Background(), s.key(instanceID)).Result()
+	if err == redis.Nil {
+		return "", time.Time{}, false, nil
+	}
+	if err != nil {
+		return "", time.Time{}, false, fmt.Errorf("failed to read token from redis: %w", err)
+	}
+
+	var entry redisTokenEntry
+	if err := json.Unmarshal([]byte(val), &entry); err != nil {
+		return "", time.Time{}, false, fmt.Errorf("failed to decode token from redis: %w", err)
+	}
+	return entry.Token, time.UnixMilli(entry.ExpiresAtMs), true, nil
+}
+
+func (s *RedisTokenStore) Set(instanceID, token string, expiresAt time.Time) error {
+	entry := redisTokenEntry{Token: token, ExpiresAtMs: expiresAt.UnixMilli()}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode token for redis: %w", err)
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := s.client.Set(context.Background(), s.key(instanceID), data, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to write token to redis: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisTokenStore) Delete(instanceID string) error {
+	if err := s.client.Del(context.Background(), s.key(instanceID)).Err(); err != nil {
+		return fmt.Errorf("failed to delete token from redis: %w", err)
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package xk6ags
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 	"go.k6.io/k6/js/modules"
+	"golang.org/x/time/rate"
 
 	ags "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/ags/v20250920"
 )
@@ -28,6 +33,21 @@ type AGS struct {
 	host                  string
 	dataPlaneDomainSuffix string
 	tokenManager          *tokenManager
+
+	retryPolicy *RetryPolicy[*Response] // 数据面请求的重试策略，nil 表示不重试
+
+	// 熔断器/限流器按 instanceID 各自独立，避免某个沙箱实例的失败或限流影响同一 VU
+	// 对其他实例的调用，见 resilience.go 的 circuitBreakerFor/rateLimiterFor
+	circuitBreakerTemplate *CircuitBreaker // 熔断器配置模板，nil 表示不熔断
+	circuitBreakersMu      sync.Mutex
+	circuitBreakers        map[string]*CircuitBreaker
+
+	rateLimit      rate.Limit // 限流速率，<= 0 表示不限流
+	rateBurst      int
+	rateLimitersMu sync.Mutex
+	rateLimiters   map[string]*rate.Limiter
+
+	auditRecorder *auditRecorder // 控制面调用审计，nil 表示未启用（见 AUDIT_ENABLED）
 }
 
 // Exports implements modules.Instance.
@@ -52,11 +72,36 @@ func (m *AGS) Exports() modules.Exports {
 			"runAsyncStress":             m.RunAsyncStress,
 			"getAsyncStressPendingCount": m.GetAsyncStressPendingCount,
 			"getAsyncStressResults":      m.GetAsyncStressResults,
+			// HTTP 负载生成
+			"runHTTPLoad":                  m.RunHTTPLoad,
+			"runAsyncHTTPLoad":             m.RunAsyncHTTPLoad,
+			"getAsyncHTTPLoadPendingCount": m.GetAsyncHTTPLoadPendingCount,
+			"getAsyncHTTPLoadResults":      m.GetAsyncHTTPLoadResults,
+			// 分布式多实例压测
+			"runDistributedStress": m.RunDistributedStress,
+			"getBatchReport":       m.GetBatchReport,
+			// 指标
+			"getMetricsSnapshot": m.GetMetricsSnapshot,
+			"startMetricsServer": m.StartMetricsServer,
+			// 异步结果持久化
+			"flushAsyncResults":   m.FlushAsyncResults,
+			"listAsyncResultRuns": m.ListAsyncResultRuns,
 		},
 	}
 }
 
 func (m *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	agsInstance := newAGS(vu, tokenStoreFromEnv())
+
+	if err := agsInstance.RestoreAsyncStop(); err != nil {
+		panic(fmt.Sprintf("failed to restore async stop tasks: %v", err))
+	}
+
+	return agsInstance
+}
+
+// newAGS 根据环境变量构造客户端配置，并用 store 初始化 tokenManager
+func newAGS(vu modules.VU, store TokenStore) *AGS {
 	tencentcloudSecretID := strings.TrimSpace(os.Getenv("TENCENTCLOUD_SECRET_ID"))
 	tencentcloudSecretKey := strings.TrimSpace(os.Getenv("TENCENTCLOUD_SECRET_KEY"))
 	tencentcloudRegion := strings.TrimSpace(os.Getenv("TENCENTCLOUD_REGION"))
@@ -79,6 +124,8 @@ func (m *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 
 	client, _ := ags.NewClient(cred, tencentcloudRegion, cpf)
 
+	maybeStartAutoObservability(tencentcloudSecretID, tencentcloudSecretKey, tencentcloudRegion)
+
 	agsInstance := &AGS{
 		vu:                    vu,
 		client:                client,
@@ -87,8 +134,48 @@ func (m *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 		region:                tencentcloudRegion,
 		host:                  host,
 		dataPlaneDomainSuffix: dataPlaneDomainSuffix,
+		auditRecorder:         getAuditRecorder(tencentcloudSecretID, tencentcloudSecretKey),
 	}
-	agsInstance.tokenManager = newTokenManager(agsInstance)
+	agsInstance.tokenManager = newTokenManagerWithStore(agsInstance, store)
 
 	return agsInstance
 }
+
+// NewAGSWithTokenStore 以指定的 TokenStore 构造一个 AGS 实例，供需要自定义 token 缓存后端
+// （如测试场景下的假 store，或显式指定的 Redis 连接）的调用方使用，opts 可进一步配置
+// 数据面请求的重试/熔断/限流行为（见 WithRetryPolicy/WithCircuitBreaker/WithRateLimit）
+func NewAGSWithTokenStore(vu modules.VU, store TokenStore, opts ...AGSOption) *AGS {
+	agsInstance := newAGS(vu, store)
+	for _, opt := range opts {
+		opt(agsInstance)
+	}
+	return agsInstance
+}
+
+// tokenStoreFromEnv 根据 TOKEN_STORE 环境变量选择 token 缓存后端：
+// "redis" 时使用 REDIS_ADDR/REDIS_PASSWORD/REDIS_DB 连接 Redis 作为跨进程共享缓存，
+// 否则（包括未设置）使用进程内的 MemoryTokenStore
+func tokenStoreFromEnv() TokenStore {
+	if strings.TrimSpace(os.Getenv("TOKEN_STORE")) != "redis" {
+		return NewMemoryTokenStore()
+	}
+
+	addr := strings.TrimSpace(os.Getenv("REDIS_ADDR"))
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	db := 0
+	if v := strings.TrimSpace(os.Getenv("REDIS_DB")); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			db = parsed
+		}
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       db,
+	})
+
+	return NewRedisTokenStore(client, "ags:token:")
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"sync"
 	"time"
 
 	"mvdan.cc/sh/v3/shell"
@@ -24,18 +25,23 @@ type ExecRequest struct {
 
 // ExecResponse 执行命令响应
 type ExecResponse struct {
-	Success  bool   `json:"success"`            // 是否成功
-	ExitCode int    `json:"exit_code"`          // 退出码
-	Output   string `json:"output"`             // 标准输出和标准错误合并
-	Error    string `json:"error,omitempty"`    // 错误信息
-	Duration int64  `json:"duration_ms"`        // 执行耗时（毫秒）
-	Timeout  bool   `json:"timeout,omitempty"`  // 是否超时
+	Success      bool   `json:"success"`                 // 是否成功
+	ExitCode     int    `json:"exit_code"`               // 退出码
+	Output       string `json:"output"`                  // 标准输出和标准错误合并
+	Error        string `json:"error,omitempty"`         // 错误信息
+	Duration     int64  `json:"duration_ms"`             // 执行耗时（毫秒）
+	Timeout      bool   `json:"timeout,omitempty"`       // 是否超时
+	PolicyDenied bool   `json:"policy_denied,omitempty"` // 是否被命令策略拒绝
 }
 
 // Server HTTP 服务
 type Server struct {
-	defaultTimeout int // 默认超时时间（秒）
-	maxTimeout     int // 最大超时时间（秒）
+	defaultTimeout int     // 默认超时时间（秒）
+	maxTimeout     int     // 最大超时时间（秒）
+	policy         *Policy // 命令策略，为 nil 表示不启用策略检查
+
+	execMu sync.Mutex              // 保护 execs
+	execs  map[string]*runningExec // 正在执行、可通过 DELETE /exec/{id} 取消的命令
 }
 
 func NewServer() *Server {
@@ -53,10 +59,22 @@ func NewServer() *Server {
 		}
 	}
 
-	return &Server{
+	s := &Server{
 		defaultTimeout: defaultTimeout,
 		maxTimeout:     maxTimeout,
 	}
+
+	if policyFile := os.Getenv("POLICY_FILE"); policyFile != "" {
+		policy, err := LoadPolicy(policyFile)
+		if err != nil {
+			log.Fatalf("failed to load policy file %s: %v", policyFile, err)
+		}
+		policy.watchReload()
+		s.policy = policy
+		log.Printf("command policy loaded from %s (reload with SIGHUP)", policyFile)
+	}
+
+	return s
 }
 
 // handleHealth 健康检查端点
@@ -107,7 +125,7 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// 执行命令
-	resp := s.executeCommand(req.Command, req.Env, req.Workdir, timeout)
+	resp := s.executeCommand(req.Command, req.Env, req.Workdir, timeout, r.RemoteAddr)
 
 	w.Header().Set("Content-Type", "application/json")
 	if resp.Success {
@@ -119,7 +137,7 @@ func (s *Server) handleExec(w http.ResponseWriter, r *http.Request) {
 }
 
 // executeCommand 执行命令
-func (s *Server) executeCommand(command string, env map[string]string, workdir string, timeoutSec int) ExecResponse {
+func (s *Server) executeCommand(command string, env map[string]string, workdir string, timeoutSec int, remoteAddr string) ExecResponse {
 	start := time.Now()
 
 	// 构建环境变量函数，用于解析命令中的变量
@@ -149,6 +167,22 @@ func (s *Server) executeCommand(command string, env map[string]string, workdir s
 		}
 	}
 
+	if s.policy != nil {
+		decision := s.policy.Evaluate(command, args, env, workdir, timeoutSec)
+		if decision.Denied {
+			log.Printf("policy denied command from %s: %q: %s", remoteAddr, command, decision.Reason)
+			return ExecResponse{
+				Success:      false,
+				ExitCode:     -1,
+				Error:        "policy: " + decision.Reason,
+				PolicyDenied: true,
+				Duration:     time.Since(start).Milliseconds(),
+			}
+		}
+		env = decision.FilteredEnv
+		timeoutSec = decision.EffectiveTimeout
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
 	defer cancel()
 
@@ -235,14 +269,19 @@ func main() {
 
 	http.HandleFunc("/health", server.handleHealth)
 	http.HandleFunc("/exec", server.handleExec)
+	http.HandleFunc("/exec/stream", server.handleExecStream)
+	http.HandleFunc("/exec/", server.handleExecCancel)
 
 	addr := ":" + port
 	log.Printf("shell2http server starting on port %s...", port)
 	log.Printf("  - Default timeout: %ds", server.defaultTimeout)
 	log.Printf("  - Max timeout: %ds", server.maxTimeout)
+	log.Printf("  - Policy enabled: %t", server.policy != nil)
 	log.Printf("Endpoints:")
-	log.Printf("  GET  /health  - Health check")
-	log.Printf("  POST /exec    - Execute shell command")
+	log.Printf("  GET    /health       - Health check")
+	log.Printf("  POST   /exec         - Execute shell command")
+	log.Printf("  POST   /exec/stream  - Execute shell command, streaming NDJSON/SSE output")
+	log.Printf("  DELETE /exec/{id}    - Cancel a streaming command")
 
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Server failed: %v", err)
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PolicyConfig 是 /exec 命令策略的声明式配置，由 POLICY_FILE 指向的 YAML/JSON 文件加载
+type PolicyConfig struct {
+	AllowedCommands         []string          `json:"allowed_commands,omitempty" yaml:"allowed_commands,omitempty"`                   // 允许执行的二进制名（args[0]），为空表示不做白名单限制
+	DeniedCommands          []string          `json:"denied_commands,omitempty" yaml:"denied_commands,omitempty"`                     // 禁止执行的二进制名，优先级高于白名单
+	CommandArgs             map[string]string `json:"command_args,omitempty" yaml:"command_args,omitempty"`                           // 针对某个二进制的参数正则约束（整体匹配 args[1:] 拼接后的字符串）
+	WorkdirPrefixes         []string          `json:"workdir_prefixes,omitempty" yaml:"workdir_prefixes,omitempty"`                   // 允许的工作目录前缀，为空表示不限制
+	AllowedEnvVars          []string          `json:"allowed_env_vars,omitempty" yaml:"allowed_env_vars,omitempty"`                   // 允许透传的环境变量名，为空表示不限制
+	RejectUnknownEnv        bool              `json:"reject_unknown_env,omitempty" yaml:"reject_unknown_env,omitempty"`               // true: 命中白名单之外的变量直接拒绝请求；false（默认）: 静默丢弃
+	CommandTimeouts         map[string]int    `json:"command_timeouts,omitempty" yaml:"command_timeouts,omitempty"`                   // 针对某个二进制的超时上限（秒），实际超时取请求值与上限的较小者
+	DenyShellMetacharacters bool              `json:"deny_shell_metacharacters,omitempty" yaml:"deny_shell_metacharacters,omitempty"` // true 时拒绝包含 shell 元字符的原始命令
+}
+
+// shellMetacharacters 命中任意一个即视为潜在的命令拼接/注入
+var shellMetacharacters = []string{";", "&&", "|", "`", "$("}
+
+// Policy 是 PolicyConfig 加载后的可查询形态，支持通过 SIGHUP 热重载
+type Policy struct {
+	path string
+
+	mu        sync.RWMutex
+	cfg       PolicyConfig
+	argsRegex map[string]*regexp.Regexp
+}
+
+// LoadPolicy 从 path 加载策略文件，扩展名为 .yaml/.yml 时按 YAML 解析，否则按 JSON 解析
+func LoadPolicy(path string) (*Policy, error) {
+	p := &Policy{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload 重新读取策略文件并原子替换生效配置
+func (p *Policy) Reload() error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("读取策略文件失败: %w", err)
+	}
+
+	var cfg PolicyConfig
+	if ext := strings.ToLower(filepath.Ext(p.path)); ext == ".yaml" || ext == ".yml" {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("解析 YAML 策略文件失败: %w", err)
+		}
+	} else {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return fmt.Errorf("解析 JSON 策略文件失败: %w", err)
+		}
+	}
+
+	argsRegex := make(map[string]*regexp.Regexp, len(cfg.CommandArgs))
+	for bin, pattern := range cfg.CommandArgs {
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("编译命令 %q 的参数正则失败: %w", bin, err)
+		}
+		argsRegex[bin] = re
+	}
+
+	p.mu.Lock()
+	p.cfg = cfg
+	p.argsRegex = argsRegex
+	p.mu.Unlock()
+
+	return nil
+}
+
+// watchReload 监听 SIGHUP，收到后重新加载策略文件；重载失败时保留旧策略并打印错误
+func (p *Policy) watchReload() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	go func() {
+		for range sigCh {
+			if err := p.Reload(); err != nil {
+				fmt.Fprintf(os.Stderr, "重载策略文件失败: %v\n", err)
+			}
+		}
+	}()
+}
+
+// Decision 是一次策略评估的结果
+type Decision struct {
+	Denied           bool
+	Reason           string
+	FilteredEnv      map[string]string
+	EffectiveTimeout int
+}
+
+// Evaluate 对一次 /exec 请求做策略检查，返回过滤后的环境变量和生效的超时时间
+func (p *Policy) Evaluate(rawCommand string, args []string, env map[string]string, workdir string, timeoutSec int) Decision {
+	p.mu.RLock()
+	cfg := p.cfg
+	argsRegex := p.argsRegex
+	p.mu.RUnlock()
+
+	decision := Decision{FilteredEnv: make(map[string]string, len(env)), EffectiveTimeout: timeoutSec}
+
+	if cfg.DenyShellMetacharacters && containsShellMetacharacters(rawCommand) {
+		decision.Denied = true
+		decision.Reason = "command contains disallowed shell metacharacters"
+		return decision
+	}
+
+	if len(args) == 0 {
+		return decision
+	}
+	bin := args[0]
+
+	for _, denied := range cfg.DeniedCommands {
+		if denied == bin {
+			decision.Denied = true
+			decision.Reason = fmt.Sprintf("command %q is denied", bin)
+			return decision
+		}
+	}
+
+	if len(cfg.AllowedCommands) > 0 && !containsString(cfg.AllowedCommands, bin) {
+		decision.Denied = true
+		decision.Reason = fmt.Sprintf("command %q is not in the allowlist", bin)
+		return decision
+	}
+
+	if re, ok := argsRegex[bin]; ok {
+		joinedArgs := strings.Join(args[1:], " ")
+		if !re.MatchString(joinedArgs) {
+			decision.Denied = true
+			decision.Reason = fmt.Sprintf("arguments for %q do not match policy", bin)
+			return decision
+		}
+	}
+
+	if workdir != "" && len(cfg.WorkdirPrefixes) > 0 {
+		allowed := false
+		for _, prefix := range cfg.WorkdirPrefixes {
+			if isWithinPrefix(workdir, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			decision.Denied = true
+			decision.Reason = fmt.Sprintf("workdir %q is outside the allowed prefixes", workdir)
+			return decision
+		}
+	}
+
+	for name, value := range env {
+		if len(cfg.AllowedEnvVars) == 0 || containsString(cfg.AllowedEnvVars, name) {
+			decision.FilteredEnv[name] = value
+			continue
+		}
+		if cfg.RejectUnknownEnv {
+			decision.Denied = true
+			decision.Reason = fmt.Sprintf("env var %q is not in the allowlist", name)
+			return decision
+		}
+		// 默认静默丢弃不在白名单内的变量
+	}
+
+	if cap, ok := cfg.CommandTimeouts[bin]; ok && cap > 0 && (timeoutSec <= 0 || cap < timeoutSec) {
+		decision.EffectiveTimeout = cap
+	}
+
+	return decision
+}
+
+// isWithinPrefix 判断 dir 是否等于 prefix 本身，或位于 prefix 目录之下；按路径分段比较，
+// 而非裸字符串前缀匹配，避免 "/srv/app" 误放行 "/srv/app_evil"
+func isWithinPrefix(dir, prefix string) bool {
+	dir = filepath.Clean(dir)
+	prefix = filepath.Clean(prefix)
+	if dir == prefix {
+		return true
+	}
+	return strings.HasPrefix(dir, prefix+string(filepath.Separator))
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func containsShellMetacharacters(command string) bool {
+	for _, meta := range shellMetacharacters {
+		if strings.Contains(command, meta) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"mvdan.cc/sh/v3/shell"
+)
+
+// StreamEvent 是 /exec/stream 推送给客户端的一条事件，既用于 NDJSON 也用于 SSE
+type StreamEvent struct {
+	ID       string `json:"id,omitempty"`        // 仅首条事件携带，供客户端后续 DELETE /exec/{id} 取消
+	Type     string `json:"type"`                // start, stdout, stderr, exit, error
+	Data     string `json:"data,omitempty"`      // stdout/stderr 的一行输出，或 error 的错误信息
+	ExitCode int    `json:"exit_code,omitempty"` // Type == exit 时有效
+	Duration int64  `json:"duration_ms,omitempty"`
+	Timeout  bool   `json:"timeout,omitempty"`
+}
+
+// runningExec 是一个正在执行、可被 DELETE /exec/{id} 取消的命令
+type runningExec struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+}
+
+// resolveExec 解析命令行并做策略检查，返回可直接执行的 args/env/timeout；
+// 若应当短路返回给客户端（解析失败、空命令、策略拒绝），deny 非 nil
+func (s *Server) resolveExec(command string, env map[string]string, workdir string, timeoutSec int, remoteAddr string) (args []string, resolvedEnv map[string]string, resolvedTimeout int, deny *ExecResponse) {
+	envFunc := func(name string) string {
+		if v, ok := env[name]; ok {
+			return v
+		}
+		return os.Getenv(name)
+	}
+
+	args, err := shell.Fields(command, envFunc)
+	if err != nil {
+		return nil, nil, 0, &ExecResponse{Success: false, ExitCode: -1, Error: "failed to parse command: " + err.Error()}
+	}
+	if len(args) == 0 {
+		return nil, nil, 0, &ExecResponse{Success: false, ExitCode: -1, Error: "empty command"}
+	}
+
+	resolvedEnv = env
+	resolvedTimeout = timeoutSec
+
+	if s.policy != nil {
+		decision := s.policy.Evaluate(command, args, env, workdir, timeoutSec)
+		if decision.Denied {
+			log.Printf("policy denied command from %s: %q: %s", remoteAddr, command, decision.Reason)
+			return nil, nil, 0, &ExecResponse{Success: false, ExitCode: -1, Error: "policy: " + decision.Reason, PolicyDenied: true}
+		}
+		resolvedEnv = decision.FilteredEnv
+		resolvedTimeout = decision.EffectiveTimeout
+	}
+
+	return args, resolvedEnv, resolvedTimeout, nil
+}
+
+// registerExec 登记一个正在运行的命令，返回可用于 DELETE /exec/{id} 取消的 id
+func (s *Server) registerExec(cmd *exec.Cmd, cancel context.CancelFunc) string {
+	id := newExecID()
+
+	s.execMu.Lock()
+	if s.execs == nil {
+		s.execs = make(map[string]*runningExec)
+	}
+	s.execs[id] = &runningExec{cmd: cmd, cancel: cancel}
+	s.execMu.Unlock()
+
+	return id
+}
+
+func (s *Server) unregisterExec(id string) {
+	s.execMu.Lock()
+	delete(s.execs, id)
+	s.execMu.Unlock()
+}
+
+func newExecID() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// handleExecStream 以 NDJSON 或 SSE 流式返回命令的 stdout/stderr，结束后发送一条 exit 事件
+func (s *Server) handleExecStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExecRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"invalid json: `+err.Error()+`"}`, http.StatusBadRequest)
+		return
+	}
+	if req.Command == "" {
+		http.Error(w, `{"error":"command is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	timeout := s.defaultTimeout
+	if req.Timeout > 0 {
+		timeout = req.Timeout
+	}
+	if timeout > s.maxTimeout {
+		timeout = s.maxTimeout
+	}
+
+	args, env, timeout, deny := s.resolveExec(req.Command, req.Env, req.Workdir, timeout, r.RemoteAddr)
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream") || r.URL.Query().Get("format") == "sse"
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"streaming not supported"}`, http.StatusInternalServerError)
+		return
+	}
+
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	// writeMu 串行化对 w 的写入：stdout/stderr 各自起一个 goroutine 并发调用 writeEvent，
+	// 不加锁会导致两路输出交错，把 NDJSON/SSE 的一行/一帧拆散
+	var writeMu sync.Mutex
+	writeEvent := func(ev StreamEvent) {
+		data, _ := json.Marshal(ev)
+
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if sse {
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+		} else {
+			w.Write(data)
+			w.Write([]byte("\n"))
+		}
+		flusher.Flush()
+	}
+
+	if deny != nil {
+		writeEvent(StreamEvent{Type: "error", Data: deny.Error})
+		return
+	}
+
+	if req.Workdir != "" {
+		if info, err := os.Stat(req.Workdir); err != nil || !info.IsDir() {
+			writeEvent(StreamEvent{Type: "error", Data: "workdir does not exist or is not a directory: " + req.Workdir})
+			return
+		}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = req.Workdir
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	// 独立进程组，便于客户端断开或显式取消时整组杀掉，避免留下孤儿子进程
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		writeEvent(StreamEvent{Type: "error", Data: "failed to attach stdout: " + err.Error()})
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		writeEvent(StreamEvent{Type: "error", Data: "failed to attach stderr: " + err.Error()})
+		return
+	}
+
+	if err := cmd.Start(); err != nil {
+		writeEvent(StreamEvent{Type: "error", Data: "failed to start command: " + err.Error()})
+		return
+	}
+
+	id := s.registerExec(cmd, cancel)
+	defer s.unregisterExec(id)
+	writeEvent(StreamEvent{ID: id, Type: "start"})
+
+	// ctx 取消（超时、客户端断开连接或 DELETE /exec/{id}）时，标准库只会杀掉 cmd.Process 本身，
+	// 这里额外杀掉整个进程组，避免遗留未被回收的子进程
+	go func() {
+		<-ctx.Done()
+		killProcessGroup(cmd)
+	}()
+
+	var wg sync.WaitGroup
+	pump := func(streamType string, r io.Reader) {
+		defer wg.Done()
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			writeEvent(StreamEvent{Type: streamType, Data: scanner.Text()})
+		}
+	}
+	wg.Add(2)
+	go pump("stdout", stdout)
+	go pump("stderr", stderr)
+	wg.Wait()
+
+	waitErr := cmd.Wait()
+	duration := time.Since(start).Milliseconds()
+
+	exitCode := 0
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	errMsg := ""
+	if timedOut {
+		exitCode = -1
+		errMsg = fmt.Sprintf("command timed out after %d seconds", timeout)
+	} else if waitErr != nil {
+		if exitErr, ok := waitErr.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			exitCode = -1
+			errMsg = waitErr.Error()
+		}
+	}
+
+	writeEvent(StreamEvent{Type: "exit", ExitCode: exitCode, Duration: duration, Timeout: timedOut, Data: errMsg})
+}
+
+// handleExecCancel 处理 DELETE /exec/{id}，向对应进程组发送 SIGKILL
+func (s *Server) handleExecCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/exec/")
+	if id == "" || id == "stream" {
+		http.Error(w, `{"error":"exec id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.execMu.Lock()
+	running, ok := s.execs[id]
+	s.execMu.Unlock()
+	if !ok {
+		http.Error(w, `{"error":"unknown exec id"}`, http.StatusNotFound)
+		return
+	}
+
+	killProcessGroup(running.cmd)
+	running.cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "id": id})
+}
+
+// killProcessGroup 向命令所在的进程组发送 SIGKILL，确保子进程也被清理
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,154 @@
+// Package audit 为 AGS 控制面调用（沙箱工具/预热任务的创建、删除、查询等）提供
+// CloudAudit 风格的结构化事件流：每次调用产生一条 Record，经可插拔的 Sink
+// 落盘/上报，方便用户事后回放某次压测期间到底创建、删除过哪些沙箱资源，
+// 用于排查 flaky 的 k6 运行，以及按使用量做 chargeback。
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Record 是一条结构化的审计事件
+type Record struct {
+	Timestamp  time.Time      `json:"timestamp"`
+	Action     string         `json:"action"`           // API 动作名，如 CreateSandboxTool
+	Params     map[string]any `json:"params,omitempty"` // 经 Redactor 脱敏后的请求参数
+	Success    bool           `json:"success"`
+	Code       string         `json:"code,omitempty"`    // 错误码，成功时为空
+	Message    string         `json:"message,omitempty"` // 错误信息，成功时为空
+	RequestID  string         `json:"request_id,omitempty"`
+	LatencyMs  int64          `json:"latency_ms"`
+	RetryCount int            `json:"retry_count"`
+	Caller     string         `json:"caller"` // precache 任务 ID 或 k6 VU+iter 标识
+}
+
+// Redactor 对请求参数做脱敏处理，返回值会被写入 Record.Params
+type Redactor func(params map[string]any) map[string]any
+
+// defaultRedactKeys 是内置的敏感字段名，不区分大小写匹配
+var defaultRedactKeys = []string{"secretkey", "secretid", "token", "password", "authorization"}
+
+// DefaultRedactor 返回一个屏蔽内置敏感字段的 Redactor，extraKeys 可追加额外需要脱敏的字段名
+func DefaultRedactor(extraKeys ...string) Redactor {
+	keys := make(map[string]struct{}, len(defaultRedactKeys)+len(extraKeys))
+	for _, k := range defaultRedactKeys {
+		keys[lower(k)] = struct{}{}
+	}
+	for _, k := range extraKeys {
+		keys[lower(k)] = struct{}{}
+	}
+
+	return func(params map[string]any) map[string]any {
+		if params == nil {
+			return nil
+		}
+		redacted := make(map[string]any, len(params))
+		for k, v := range params {
+			if _, ok := keys[lower(k)]; ok {
+				redacted[k] = "***REDACTED***"
+				continue
+			}
+			redacted[k] = v
+		}
+		return redacted
+	}
+}
+
+func lower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + ('a' - 'A')
+		}
+	}
+	return string(b)
+}
+
+// Sink 消费审计事件，具体实现决定落盘方式（本地文件、Kafka、CLS...）
+type Sink interface {
+	Emit(rec Record) error
+	// Close 释放 Sink 持有的资源（文件句柄、连接等）
+	Close() error
+}
+
+// Recorder 把一次调用包装为 Record 并投递给所有配置的 Sink；任意 Sink 写入失败只记日志、
+// 不影响调用方拿到真实的 AGS 响应
+type Recorder struct {
+	sinks    []Sink
+	redactor Redactor
+	onError  func(sink Sink, err error)
+}
+
+// NewRecorder 创建 Recorder，redactor 为 nil 时使用 DefaultRedactor()
+func NewRecorder(sinks []Sink, redactor Redactor, onError func(sink Sink, err error)) *Recorder {
+	if redactor == nil {
+		redactor = DefaultRedactor()
+	}
+	return &Recorder{sinks: sinks, redactor: redactor, onError: onError}
+}
+
+// Emit 构造一条 Record 并投递给所有 Sink
+func (r *Recorder) Emit(action, caller string, params map[string]any, start time.Time, retryCount int, requestID string, err error) {
+	rec := Record{
+		Timestamp:  time.Now(),
+		Action:     action,
+		Params:     r.redactor(params),
+		Success:    err == nil,
+		RequestID:  requestID,
+		LatencyMs:  time.Since(start).Milliseconds(),
+		RetryCount: retryCount,
+		Caller:     caller,
+	}
+	if err != nil {
+		rec.Message = err.Error()
+		if code, ok := errCode(err); ok {
+			rec.Code = code
+		}
+	}
+
+	for _, sink := range r.sinks {
+		if sinkErr := sink.Emit(rec); sinkErr != nil && r.onError != nil {
+			r.onError(sink, sinkErr)
+		}
+	}
+}
+
+// Close 关闭所有 Sink
+func (r *Recorder) Close() error {
+	var firstErr error
+	for _, sink := range r.sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// errCode 尝试从腾讯云 SDK 错误中提取 Code 字段，非 SDK 错误返回 false
+func errCode(err error) (string, bool) {
+	type coder interface{ GetCode() string }
+	var c coder
+	if errors.As(err, &c) {
+		return c.GetCode(), true
+	}
+	return "", false
+}
+
+// marshalParams 是一个便于调用方把任意 request 结构体转换为 map[string]any 的辅助函数，
+// 供 AGSAPI 包装层在没有现成 map 时使用
+func marshalParams(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"goscripts/yunapi/ags"
+)
+
+// AGSAPI 是 cmd/precache 实际调用到的那部分 *ags.Client 方法集合，Client 和未经审计的
+// *ags.Client 都满足该接口，调用方可以按需决定是否经过审计层
+type AGSAPI interface {
+	Region() string
+	CreatePreCacheImageTask(req *ags.CreatePreCacheImageTaskRequest) (*ags.CreatePreCacheImageTaskResponse, error)
+	DescribePreCacheImageTask(req *ags.DescribePreCacheImageTaskRequest) (*ags.DescribePreCacheImageTaskResponse, error)
+	CreateSandboxTool(req *ags.CreateSandboxToolRequest) (*ags.CreateSandboxToolResponse, error)
+	DeleteSandboxTool(req *ags.DeleteSandboxToolRequest) (*ags.DeleteSandboxToolResponse, error)
+	WaitToolActive(ctx context.Context, toolID string, opts *ags.WaitToolActiveOptions) error
+}
+
+// Client 包装 *ags.Client，在每次控制面调用前后向 Recorder 投递一条 Record。
+// 和 yunapi/ags.Client 包装 SDK 客户端的方式一样，只覆盖直接被调用的方法；通过
+// WaitToolActive 间接发起的 DescribeSandboxToolList 轮询不经过这一层审计
+type Client struct {
+	*ags.Client
+	recorder   *Recorder
+	caller     string // precache 任务 ID，如 "image:nginx:latest"
+	retryCount int
+}
+
+// Wrap 返回一个经审计的 Client；recorder 为 nil 时直接返回未包装的 client，
+// 调用方无需在 Audit 未启用时做额外的 nil 判断
+func Wrap(client *ags.Client, recorder *Recorder, caller string, retryCount int) AGSAPI {
+	if recorder == nil {
+		return client
+	}
+	return &Client{Client: client, recorder: recorder, caller: caller, retryCount: retryCount}
+}
+
+// CreatePreCacheImageTask 创建镜像预热任务并记录审计事件
+func (c *Client) CreatePreCacheImageTask(req *ags.CreatePreCacheImageTaskRequest) (*ags.CreatePreCacheImageTaskResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.CreatePreCacheImageTask(req)
+	requestID := ""
+	if resp != nil {
+		requestID = resp.Response.RequestId
+	}
+	c.recorder.Emit("CreatePreCacheImageTask", c.caller, marshalParams(req), start, c.retryCount, requestID, err)
+	return resp, err
+}
+
+// DescribePreCacheImageTask 查询镜像预热任务并记录审计事件
+func (c *Client) DescribePreCacheImageTask(req *ags.DescribePreCacheImageTaskRequest) (*ags.DescribePreCacheImageTaskResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.DescribePreCacheImageTask(req)
+	requestID := ""
+	if resp != nil {
+		requestID = resp.Response.RequestId
+	}
+	c.recorder.Emit("DescribePreCacheImageTask", c.caller, marshalParams(req), start, c.retryCount, requestID, err)
+	return resp, err
+}
+
+// CreateSandboxTool 创建沙箱工具并记录审计事件
+func (c *Client) CreateSandboxTool(req *ags.CreateSandboxToolRequest) (*ags.CreateSandboxToolResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.CreateSandboxTool(req)
+	requestID := ""
+	if resp != nil && resp.Response.RequestId != nil {
+		requestID = *resp.Response.RequestId
+	}
+	c.recorder.Emit("CreateSandboxTool", c.caller, marshalParams(req), start, c.retryCount, requestID, err)
+	return resp, err
+}
+
+// DeleteSandboxTool 删除沙箱工具并记录审计事件
+func (c *Client) DeleteSandboxTool(req *ags.DeleteSandboxToolRequest) (*ags.DeleteSandboxToolResponse, error) {
+	start := time.Now()
+	resp, err := c.Client.DeleteSandboxTool(req)
+	requestID := ""
+	if resp != nil && resp.Response.RequestId != nil {
+		requestID = *resp.Response.RequestId
+	}
+	c.recorder.Emit("DeleteSandboxTool", c.caller, marshalParams(req), start, c.retryCount, requestID, err)
+	return resp, err
+}
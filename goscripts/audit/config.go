@@ -0,0 +1,48 @@
+package audit
+
+import (
+	"fmt"
+	"log/slog"
+
+	"goscripts/config"
+)
+
+// NewRecorderFromConfig 根据 config.AuditConfig 构建 Recorder，cfg.Enabled 为 false 时返回
+// (nil, nil)，调用方可以直接把返回值传给 Wrap，未启用时 Wrap 会原样返回未包装的客户端
+func NewRecorderFromConfig(cfg config.AuditConfig) (*Recorder, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var sinks []Sink
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "file":
+			sink, err := NewJSONLSink(cfg.FilePath)
+			if err != nil {
+				return nil, fmt.Errorf("初始化审计文件 Sink 失败: %w", err)
+			}
+			sinks = append(sinks, sink)
+		case "kafka":
+			sink, err := NewKafkaSink(cfg.KafkaBrokers, cfg.KafkaTopic)
+			if err != nil {
+				return nil, fmt.Errorf("初始化审计 Kafka Sink 失败: %w", err)
+			}
+			sinks = append(sinks, sink)
+		case "cls":
+			sink, err := NewCLSSink(cfg.CLSEndpoint, config.C.TencentCloud.SecretID, config.C.TencentCloud.SecretKey, cfg.CLSTopicID)
+			if err != nil {
+				return nil, fmt.Errorf("初始化审计 CLS Sink 失败: %w", err)
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, fmt.Errorf("未知的审计 Sink 类型: %s", name)
+		}
+	}
+
+	redactor := DefaultRedactor(cfg.RedactKeys...)
+	onError := func(sink Sink, err error) {
+		slog.Warn("写入审计事件失败", "sink", fmt.Sprintf("%T", sink), "error", err)
+	}
+	return NewRecorder(sinks, redactor, onError), nil
+}
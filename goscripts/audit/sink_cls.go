@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	cls "github.com/tencentcloud/tencentcloud-cls-sdk-go"
+)
+
+// clsCallback 实现 cls.CallBack，SendLog 是异步投递，Success/Fail 在投递完成后被 SDK
+// 回调；这里只在失败时记日志，不阻塞调用方
+type clsCallback struct{}
+
+func (clsCallback) Success(result *cls.Result) {}
+
+func (clsCallback) Fail(result *cls.Result) {
+	slog.Warn("上报审计事件到 CLS 失败", "result", result)
+}
+
+// CLSSink 把每条 Record 上报到腾讯云日志服务（CLS），使用 SDK 自带的异步 Producer，
+// 便于和已有的云上日志检索/告警能力结合
+type CLSSink struct {
+	producer *cls.AsyncProducerClient
+	topicID  string
+}
+
+// NewCLSSink 创建 CLSSink，endpoint 形如 "ap-guangzhou.cls.tencentcs.com"
+func NewCLSSink(endpoint, accessKeyID, accessKeySecret, topicID string) (*CLSSink, error) {
+	cfg := cls.GetDefaultAsyncProducerClientConfig()
+	cfg.Endpoint = endpoint
+	cfg.AccessKeyID = accessKeyID
+	cfg.AccessKeySecret = accessKeySecret
+
+	producer, err := cls.NewAsyncProducerClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("创建 CLS 客户端失败: %w", err)
+	}
+	producer.Start()
+
+	return &CLSSink{producer: producer, topicID: topicID}, nil
+}
+
+// Emit 异步上报一条审计记录，record 字段以 JSON 字符串整体写入 "record" 日志字段
+func (s *CLSSink) Emit(rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("序列化审计记录失败: %w", err)
+	}
+
+	log := cls.NewCLSLog(time.Now().Unix(), map[string]string{
+		"action":  rec.Action,
+		"success": fmt.Sprintf("%t", rec.Success),
+		"caller":  rec.Caller,
+		"record":  string(data),
+	})
+
+	return s.producer.SendLog(s.topicID, log, clsCallback{})
+}
+
+// Close 等待未完成的上报请求发送完毕并关闭客户端
+func (s *CLSSink) Close() error {
+	return s.producer.Close(5000)
+}
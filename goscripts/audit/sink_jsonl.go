@@ -0,0 +1,39 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// JSONLSink 把每条 Record 以 JSON Lines 格式追加写入本地文件，是最简单、零外部依赖的 Sink
+type JSONLSink struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewJSONLSink 创建 JSONLSink，path 不存在时会被创建，已存在则以追加模式打开
+func NewJSONLSink(path string) (*JSONLSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志文件失败: %w", err)
+	}
+	return &JSONLSink{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// Emit 追加写入一条 Record
+func (s *JSONLSink) Emit(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(rec); err != nil {
+		return fmt.Errorf("写入审计记录失败: %w", err)
+	}
+	return nil
+}
+
+// Close 关闭底层文件
+func (s *JSONLSink) Close() error {
+	return s.file.Close()
+}
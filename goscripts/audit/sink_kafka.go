@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// KafkaSink 把每条 Record 序列化为 JSON 后生产到 Kafka topic
+type KafkaSink struct {
+	client *kgo.Client
+	topic  string
+}
+
+// NewKafkaSink 创建 KafkaSink，连接 brokers 并固定生产到 topic
+func NewKafkaSink(brokers []string, topic string) (*KafkaSink, error) {
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.DefaultProduceTopic(topic),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("创建 Kafka 客户端失败: %w", err)
+	}
+	return &KafkaSink{client: client, topic: topic}, nil
+}
+
+// Emit 生产一条审计记录，并阻塞等待投递回调通过 errCh 返回结果，投递失败时返回错误
+func (s *KafkaSink) Emit(rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("序列化审计记录失败: %w", err)
+	}
+
+	errCh := make(chan error, 1)
+	s.client.Produce(context.Background(), &kgo.Record{Topic: s.topic, Value: data}, func(_ *kgo.Record, err error) {
+		errCh <- err
+	})
+	if err := <-errCh; err != nil {
+		return fmt.Errorf("投递审计记录到 Kafka 失败: %w", err)
+	}
+	return nil
+}
+
+// Close 刷新未完成的生产请求并关闭客户端
+func (s *KafkaSink) Close() error {
+	s.client.Close()
+	return nil
+}
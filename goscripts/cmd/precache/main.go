@@ -3,18 +3,24 @@ package main
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
 	"os"
 	"os/signal"
 	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"goscripts/audit"
 	"goscripts/config"
+	"goscripts/metrics"
+	"goscripts/precache"
 	tuispinner "goscripts/tui/spinner"
 	"goscripts/yunapi/ags"
+	"goscripts/yunapi/monitor"
 	"goscripts/yunapi/tcr"
 
 	"github.com/google/uuid"
@@ -24,12 +30,15 @@ import (
 const (
 	ModePrecache    = "precache"    // 使用 PreCacheImageTask API
 	ModeSandboxTool = "sandboxtool" // 使用 CreateSandboxTool 并等待 Active
+	ModeOCIArtifact = "ociartifact" // 识别 Helm chart 制品，展开其引用的镜像后逐个 PreCacheImageTask
 )
 
 // Precacher 镜像预热器
 type Precacher struct {
 	cfg        config.PrecacheConfg
-	agsClient  *ags.Client
+	agsClients map[string]*ags.Client // 地域 -> 客户端，单地域模式下只有一个 entry
+	regions    []string               // 分片使用的地域列表，顺序固定以保证 round_robin 稳定
+	regionSeq  int64                  // round_robin 分片的原子计数器
 	tcrClient  *tcr.Client
 	spinner    *tuispinner.Manager
 	imageRegex *regexp.Regexp
@@ -45,18 +54,42 @@ type Precacher struct {
 	// 常量配置
 	pollInterval time.Duration
 	taskTimeout  time.Duration
+
+	// 指标
+	registry        *metrics.Registry
+	imagesTotal     *metrics.Counter
+	imagesSucceeded *metrics.Counter
+	imagesFailed    *metrics.Counter
+	retryRounds     *metrics.Counter
+	taskDuration    *metrics.Histogram
+	inflightTasks   *metrics.Gauge
+	regionCounters  map[string]*metrics.Counter // 地域 -> 该地域已提交的镜像数
+	stopMetrics     func()
+	monitorPusher   *monitor.Pusher
+	auditRecorder   *audit.Recorder // 为 nil 表示未启用审计，submitTask 会原样透传底层客户端
 }
 
 type failedTask struct {
-	imageRef   string
-	retryCount int
+	imageRef     string
+	registryType string
+	region       string // 首次提交所在的地域，重试时保持不变
+	retryCount   int
 }
 
 // NewPrecacher 创建预热器实例
 func NewPrecacher(cfg config.PrecacheConfg) (*Precacher, error) {
-	agsClient, err := ags.NewClient()
-	if err != nil {
-		return nil, fmt.Errorf("创建 AGS 客户端失败: %w", err)
+	regionList := cfg.Regions
+	if len(regionList) == 0 {
+		regionList = []string{config.C.TencentCloud.Region}
+	}
+
+	agsClients := make(map[string]*ags.Client, len(regionList))
+	for _, region := range regionList {
+		client, err := ags.NewClientForRegion(region)
+		if err != nil {
+			return nil, fmt.Errorf("创建 AGS 客户端失败: region=%s: %w", region, err)
+		}
+		agsClients[region] = client
 	}
 
 	tcrClient, err := tcr.NewClient()
@@ -77,16 +110,55 @@ func NewPrecacher(cfg config.PrecacheConfg) (*Precacher, error) {
 		concurrency = 5
 	}
 
-	return &Precacher{
-		cfg:          cfg,
-		agsClient:    agsClient,
-		tcrClient:    tcrClient,
-		spinner:      tuispinner.NewManager(),
-		imageRegex:   imageRegex,
-		semaphore:    make(chan struct{}, concurrency),
-		pollInterval: 5 * time.Second,
-		taskTimeout:  30 * time.Minute,
-	}, nil
+	auditRecorder, err := audit.NewRecorderFromConfig(config.C.Audit)
+	if err != nil {
+		return nil, fmt.Errorf("初始化审计 Recorder 失败: %w", err)
+	}
+
+	registry := metrics.NewRegistry()
+
+	regionCounters := make(map[string]*metrics.Counter, len(regionList))
+	for _, region := range regionList {
+		regionCounters[region] = registry.NewCounter(
+			fmt.Sprintf("precache_images_total_region_%s", region),
+			fmt.Sprintf("提交到地域 %s 的镜像数", region),
+		)
+	}
+
+	p := &Precacher{
+		cfg:             cfg,
+		agsClients:      agsClients,
+		regions:         regionList,
+		tcrClient:       tcrClient,
+		spinner:         tuispinner.NewManager(),
+		imageRegex:      imageRegex,
+		semaphore:       make(chan struct{}, concurrency),
+		pollInterval:    5 * time.Second,
+		taskTimeout:     30 * time.Minute,
+		registry:        registry,
+		imagesTotal:     registry.NewCounter("precache_images_total", "提交预热的镜像总数"),
+		imagesSucceeded: registry.NewCounter("precache_images_succeeded", "预热成功的镜像数"),
+		imagesFailed:    registry.NewCounter("precache_images_failed", "预热最终失败的镜像数"),
+		retryRounds:     registry.NewCounter("precache_retry_rounds", "失败重试轮次数"),
+		taskDuration:    registry.NewHistogram("precache_task_duration_seconds", "单个镜像预热任务耗时（秒）"),
+		inflightTasks:   registry.NewGauge("precache_inflight_tasks", "当前并发执行中的预热任务数"),
+		regionCounters:  regionCounters,
+		auditRecorder:   auditRecorder,
+	}
+
+	if cfg.MonitorEnabled {
+		monitorClient, err := monitor.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("创建云监控客户端失败: %w", err)
+		}
+		pushInterval := time.Duration(cfg.MonitorPushSeconds) * time.Second
+		if pushInterval <= 0 {
+			pushInterval = time.Minute
+		}
+		p.monitorPusher = monitor.NewPusher(monitorClient, cfg.MonitorNamespace, pushInterval)
+	}
+
+	return p, nil
 }
 
 // Run 执行预热任务
@@ -96,20 +168,42 @@ func (p *Precacher) Run(ctx context.Context) error {
 		mode = ModePrecache
 	}
 
+	if p.cfg.MetricsAddr != "" {
+		stop, err := metrics.StartServer(p.cfg.MetricsAddr, p.registry)
+		if err != nil {
+			return fmt.Errorf("启动 /metrics 端点失败: %w", err)
+		}
+		p.stopMetrics = stop
+	}
+	if p.monitorPusher != nil {
+		p.monitorPusher.Start()
+	}
+
 	slog.Info("开始预热任务",
 		"mode", mode,
+		"source", p.cfg.Source,
 		"registry_id", p.cfg.TCRRegistryID,
 		"namespace", p.cfg.TCRNamespace,
 		"concurrency", p.cfg.Concurrency,
 		"max_retries", p.cfg.MaxRetries,
+		"regions", p.regions,
+		"region_policy", p.cfg.RegionPolicy,
 	)
 
-	// 获取 TCR 实例信息
-	registryInfo, err := p.tcrClient.DescribeInstance(ctx, p.cfg.TCRRegistryID)
+	// tcr_enterprise（默认源）需要先查询 TCR 实例信息以拼出完整镜像地址
+	registryName := ""
+	if p.cfg.Source == "" || p.cfg.Source == "tcr_enterprise" {
+		registryInfo, err := p.tcrClient.DescribeInstance(ctx, p.cfg.TCRRegistryID)
+		if err != nil {
+			return fmt.Errorf("获取 TCR 实例信息失败: %w", err)
+		}
+		registryName = *registryInfo.RegistryName
+	}
+
+	imageSource, err := precache.NewImageSource(p.cfg, p.tcrClient, registryName)
 	if err != nil {
-		return fmt.Errorf("获取 TCR 实例信息失败: %w", err)
+		return fmt.Errorf("初始化镜像源失败: %w", err)
 	}
-	registryName := *registryInfo.RegistryName
 
 	// 后台执行预热任务
 	go func() {
@@ -120,7 +214,7 @@ func (p *Precacher) Run(ctx context.Context) error {
 		}()
 
 		// 遍历并预热镜像
-		p.processImages(ctx, registryName, mode)
+		p.processImages(ctx, imageSource, mode)
 
 		// 等待首轮完成后处理重试
 		p.wg.Wait()
@@ -133,35 +227,79 @@ func (p *Precacher) Run(ctx context.Context) error {
 
 // Shutdown 优雅关闭
 func (p *Precacher) Shutdown() {
+	if p.stopMetrics != nil {
+		p.stopMetrics()
+	}
+	if p.monitorPusher != nil {
+		p.monitorPusher.Stop()
+	}
+	if p.auditRecorder != nil {
+		if err := p.auditRecorder.Close(); err != nil {
+			slog.Warn("关闭审计 Recorder 失败", "error", err)
+		}
+	}
 	p.spinner.Quit()
 }
 
-// processImages 遍历并处理所有镜像
-func (p *Precacher) processImages(ctx context.Context, registryName, mode string) {
-	for repo, err := range p.tcrClient.Repositories(ctx, p.cfg.TCRRegistryID, p.cfg.TCRNamespace) {
+// processImages 通过 imageSource 遍历并处理所有镜像
+func (p *Precacher) processImages(ctx context.Context, imageSource precache.ImageSource, mode string) {
+	for ref, err := range imageSource.ListImages(ctx) {
 		if err != nil {
-			slog.Error("遍历镜像仓库失败", "error", err)
+			slog.Error("遍历镜像源失败", "error", err)
 			return
 		}
 
-		repoName := strings.TrimPrefix(*repo.Name, p.cfg.TCRNamespace+"/")
+		if p.imageRegex != nil && !p.imageRegex.MatchString(ref.FullName) {
+			continue
+		}
 
-		for image, err := range p.tcrClient.RepositoryImages(ctx, p.cfg.TCRRegistryID, p.cfg.TCRNamespace, repoName) {
-			if err != nil {
-				slog.Error("遍历仓库镜像失败", "repo", repoName, "error", err)
-				return
-			}
+		if mode == ModeOCIArtifact && precache.IsHelmChart(ref) {
+			p.submitChart(ctx, ref)
+			continue
+		}
 
-			fullImageName := fmt.Sprintf("%s.tencentcloudcr.com/%s/%s:%s",
-				registryName, p.cfg.TCRNamespace, repoName, *image.ImageVersion)
+		p.imagesTotal.Inc()
+		if p.monitorPusher != nil {
+			p.monitorPusher.Record("images_total", 1)
+		}
+		p.submitTask(ctx, ref.FullName, ref.RegistryType, mode, 0, p.pickRegion(ref.FullName))
+	}
+}
 
-			if p.imageRegex != nil && !p.imageRegex.MatchString(fullImageName) {
-				continue
-			}
+// submitChart 渲染一个 Helm chart 制品，为其引用的每一个镜像提交 PreCacheImageTask
+func (p *Precacher) submitChart(ctx context.Context, chartRef precache.ImageRef) {
+	images, err := precache.ResolveChartImages(ctx, chartRef, p.cfg.HelmValues)
+	if err != nil {
+		slog.Error("解析 Helm chart 镜像失败", "chart", chartRef.FullName, "error", err)
+		return
+	}
+
+	slog.Info("解析 Helm chart 完成", "chart", chartRef.FullName, "images", len(images))
 
-			p.submitTask(ctx, fullImageName, mode, 0)
+	for _, image := range images {
+		p.imagesTotal.Inc()
+		if p.monitorPusher != nil {
+			p.monitorPusher.Record("images_total", 1)
 		}
+		p.submitTask(ctx, image.FullName, image.RegistryType, ModePrecache, 0, p.pickRegion(image.FullName))
+	}
+}
+
+// pickRegion 按 cfg.RegionPolicy 为首次提交选择地域：round_robin(默认) 依次轮转，
+// hash 则按镜像名哈希取模，使同一镜像每次首次提交都落到同一地域
+func (p *Precacher) pickRegion(imageRef string) string {
+	if len(p.regions) == 1 {
+		return p.regions[0]
+	}
+
+	if p.cfg.RegionPolicy == "hash" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(imageRef))
+		return p.regions[int(h.Sum32())%len(p.regions)]
 	}
+
+	idx := atomic.AddInt64(&p.regionSeq, 1) - 1
+	return p.regions[int(idx)%len(p.regions)]
 }
 
 // processRetries 处理失败重试
@@ -182,21 +320,33 @@ func (p *Precacher) processRetries(ctx context.Context, mode string) {
 		p.failedMu.Unlock()
 
 		slog.Info("开始重试失败任务", "round", round, "count", len(tasks))
+		p.retryRounds.Inc()
+		if p.monitorPusher != nil {
+			p.monitorPusher.Record("retry_rounds", 1)
+		}
 
 		for _, task := range tasks {
 			if task.retryCount >= maxRetries {
 				slog.Warn("任务超过最大重试次数", "image", task.imageRef, "retry_count", task.retryCount)
+				p.imagesFailed.Inc()
+				if p.monitorPusher != nil {
+					p.monitorPusher.Record("images_failed", 1)
+				}
 				continue
 			}
-			p.submitTask(ctx, task.imageRef, mode, task.retryCount+1)
+			p.submitTask(ctx, task.imageRef, task.registryType, mode, task.retryCount+1, task.region)
 		}
 
 		p.wg.Wait()
 	}
 }
 
-// submitTask 提交预热任务
-func (p *Precacher) submitTask(ctx context.Context, imageRef, mode string, retryCount int) {
+// submitTask 提交预热任务，region 指定使用哪个地域的 AGS 客户端（首次提交由 pickRegion 决定，
+// 重试由 failedTask.region 保持不变）
+func (p *Precacher) submitTask(ctx context.Context, imageRef, registryType, mode string, retryCount int, region string) {
+	caller := fmt.Sprintf("image:%s", imageRef)
+	client := audit.Wrap(p.agsClients[region], p.auditRecorder, caller, retryCount)
+
 	// 先获取信号量，控制并发
 	p.semaphore <- struct{}{}
 
@@ -204,46 +354,69 @@ func (p *Precacher) submitTask(ctx context.Context, imageRef, mode string, retry
 
 	taskName := imageRef
 	if retryCount > 0 {
-		taskName = fmt.Sprintf("%s (重试 %d)", imageRef, retryCount)
+		taskName = fmt.Sprintf("%s (重试 %d, %s)", imageRef, retryCount, region)
+	} else {
+		taskName = fmt.Sprintf("%s (%s)", imageRef, region)
 	}
 	p.spinner.AddTask(imageRef, taskName)
 
+	if counter, ok := p.regionCounters[region]; ok {
+		counter.Inc()
+	}
+
+	p.inflightTasks.Inc()
+	taskStart := time.Now()
+
 	go func() {
 		defer func() {
 			<-p.semaphore
 			p.wg.Done()
+			p.inflightTasks.Dec()
+
+			duration := time.Since(taskStart).Seconds()
+			p.taskDuration.Observe(duration)
+			if p.monitorPusher != nil {
+				p.monitorPusher.Record("task_duration_seconds", duration)
+			}
 		}()
 
 		var err error
 		switch mode {
 		case ModeSandboxTool:
-			err = p.precacheViaSandboxTool(ctx, imageRef)
+			err = p.precacheViaSandboxTool(ctx, client, imageRef, registryType)
 		default:
-			err = p.precacheViaAPI(ctx, imageRef)
+			err = p.precacheViaAPI(ctx, client, imageRef, registryType)
 		}
 
 		if err != nil {
-			slog.Error("预热任务失败", "image", imageRef, "error", err)
+			slog.Error("预热任务失败", "image", imageRef, "region", region, "error", err)
 			p.spinner.FailTask(imageRef, err.Error())
-			p.addFailedTask(imageRef, retryCount)
+			p.addFailedTask(imageRef, registryType, region, retryCount)
 		} else {
-			slog.Info("预热任务成功", "image", imageRef)
+			slog.Info("预热任务成功", "image", imageRef, "region", region)
 			p.spinner.FinishTask(imageRef)
+			p.imagesSucceeded.Inc()
+			if p.monitorPusher != nil {
+				p.monitorPusher.Record("images_succeeded", 1)
+			}
 		}
 	}()
 }
 
 // precacheViaAPI 通过 PreCacheImageTask API 预热
-func (p *Precacher) precacheViaAPI(ctx context.Context, imageRef string) error {
-	createResp, err := p.agsClient.CreatePreCacheImageTask(&ags.CreatePreCacheImageTaskRequest{
+func (p *Precacher) precacheViaAPI(ctx context.Context, client audit.AGSAPI, imageRef, registryType string) error {
+	if registryType == "" {
+		registryType = "enterprise"
+	}
+	createResp, err := client.CreatePreCacheImageTask(&ags.CreatePreCacheImageTaskRequest{
 		Image:             imageRef,
-		ImageRegistryType: "enterprise",
+		ImageRegistryType: registryType,
 	})
 	if err != nil {
 		return fmt.Errorf("创建预热任务失败: %w", err)
 	}
 
-	return p.waitPreCacheComplete(ctx,
+	return p.waitPreCacheComplete(ctx, client,
 		createResp.Response.Image,
 		createResp.Response.ImageDigest,
 		createResp.Response.ImageRegistryType,
@@ -251,7 +424,7 @@ func (p *Precacher) precacheViaAPI(ctx context.Context, imageRef string) error {
 }
 
 // waitPreCacheComplete 等待预热任务完成
-func (p *Precacher) waitPreCacheComplete(ctx context.Context, imageRef, imageDigest, registryType string) error {
+func (p *Precacher) waitPreCacheComplete(ctx context.Context, client audit.AGSAPI, imageRef, imageDigest, registryType string) error {
 	ctx, cancel := context.WithTimeout(ctx, p.taskTimeout)
 	defer cancel()
 
@@ -263,7 +436,7 @@ func (p *Precacher) waitPreCacheComplete(ctx context.Context, imageRef, imageDig
 		case <-ctx.Done():
 			return fmt.Errorf("等待超时: %w", ctx.Err())
 		case <-ticker.C:
-			resp, err := p.agsClient.DescribePreCacheImageTask(&ags.DescribePreCacheImageTaskRequest{
+			resp, err := client.DescribePreCacheImageTask(&ags.DescribePreCacheImageTaskRequest{
 				Image:             imageRef,
 				ImageDigest:       &imageDigest,
 				ImageRegistryType: registryType,
@@ -283,15 +456,17 @@ func (p *Precacher) waitPreCacheComplete(ctx context.Context, imageRef, imageDig
 }
 
 // precacheViaSandboxTool 通过创建 SandboxTool 预热
-func (p *Precacher) precacheViaSandboxTool(ctx context.Context, imageRef string) error {
+func (p *Precacher) precacheViaSandboxTool(ctx context.Context, client audit.AGSAPI, imageRef, registryType string) error {
 	toolName := fmt.Sprintf("precache-%s", strings.ReplaceAll(uuid.NewString(), "-", ""))
 
-	registryType := p.cfg.ImageRegistryType
+	if registryType == "" {
+		registryType = p.cfg.ImageRegistryType
+	}
 	if registryType == "" {
 		registryType = "enterprise"
 	}
 
-	createResp, err := p.agsClient.CreateSandboxTool(&ags.CreateSandboxToolRequest{
+	createResp, err := client.CreateSandboxTool(&ags.CreateSandboxToolRequest{
 		ToolName: ags.String(toolName),
 		ToolType: ags.String("custom"),
 		RoleArn:  ags.String(p.cfg.RoleArn),
@@ -325,34 +500,36 @@ func (p *Precacher) precacheViaSandboxTool(ctx context.Context, imageRef string)
 	}
 
 	toolID := *createResp.Response.ToolId
-	defer p.cleanupTool(toolID)
+	defer p.cleanupTool(client, toolID)
 
 	waitCtx, cancel := context.WithTimeout(ctx, p.taskTimeout)
 	defer cancel()
 
-	return p.agsClient.WaitToolActive(waitCtx, toolID, &ags.WaitToolActiveOptions{
+	return client.WaitToolActive(waitCtx, toolID, &ags.WaitToolActiveOptions{
 		PollInterval: p.pollInterval,
 	})
 }
 
 // cleanupTool 清理 SandboxTool
-func (p *Precacher) cleanupTool(toolID string) {
-	if _, err := p.agsClient.DeleteSandboxTool(&ags.DeleteSandboxToolRequest{
+func (p *Precacher) cleanupTool(client audit.AGSAPI, toolID string) {
+	if _, err := client.DeleteSandboxTool(&ags.DeleteSandboxToolRequest{
 		ToolId: ags.String(toolID),
 	}); err != nil {
-		slog.Warn("删除 SandboxTool 失败", "tool_id", toolID, "error", err)
+		slog.Warn("删除 SandboxTool 失败", "tool_id", toolID, "region", client.Region(), "error", err)
 	} else {
-		slog.Info("删除 SandboxTool 成功", "tool_id", toolID)
+		slog.Info("删除 SandboxTool 成功", "tool_id", toolID, "region", client.Region())
 	}
 }
 
 // addFailedTask 添加失败任务到重试队列
-func (p *Precacher) addFailedTask(imageRef string, retryCount int) {
+func (p *Precacher) addFailedTask(imageRef, registryType, region string, retryCount int) {
 	p.failedMu.Lock()
 	defer p.failedMu.Unlock()
 	p.failedQueue = append(p.failedQueue, failedTask{
-		imageRef:   imageRef,
-		retryCount: retryCount,
+		imageRef:     imageRef,
+		registryType: registryType,
+		region:       region,
+		retryCount:   retryCount,
 	})
 }
 
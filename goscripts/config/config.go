@@ -25,6 +25,21 @@ var (
 type Config struct {
 	Cmd          CmdConfg            `koanf:"cmd"`
 	TencentCloud TencentCloundConfig `koanf:"tencent_cloud"`
+	Audit        AuditConfig         `koanf:"audit"`
+}
+
+// AuditConfig 控制面调用审计配置，详见 goscripts/audit
+type AuditConfig struct {
+	Enabled    bool     `koanf:"enabled"`     // 是否启用审计事件
+	Sinks      []string `koanf:"sinks"`       // 启用的 Sink: file / kafka / cls，可多选
+	FilePath   string   `koanf:"file_path"`   // sinks 含 file 时，JSONL 审计日志的落盘路径
+	RedactKeys []string `koanf:"redact_keys"` // 除内置敏感字段外，额外需要脱敏的请求参数字段名
+
+	KafkaBrokers []string `koanf:"kafka_brokers"` // sinks 含 kafka 时的 broker 地址列表
+	KafkaTopic   string   `koanf:"kafka_topic"`   // sinks 含 kafka 时的投递 topic
+
+	CLSEndpoint string `koanf:"cls_endpoint"` // sinks 含 cls 时的 CLS 接入点，如 "ap-guangzhou.cls.tencentcs.com"
+	CLSTopicID  string `koanf:"cls_topic_id"` // sinks 含 cls 时的日志主题 ID
 }
 
 type CmdConfg struct {
@@ -32,7 +47,7 @@ type CmdConfg struct {
 }
 
 type PrecacheConfg struct {
-	Mode              string `koanf:"mode"`                // 预热模式: precache(默认), sandboxtool
+	Mode              string `koanf:"mode"`                // 预热模式: precache(默认), sandboxtool, ociartifact
 	RoleArn           string `koanf:"role_arn"`            // 角色 ARN
 	TCRRegistryID     string `koanf:"tcr_registry_id"`     // TCR 实例 ID
 	TCRNamespace      string `koanf:"tcr_namespace"`       // TCR 命名空间
@@ -41,6 +56,25 @@ type PrecacheConfg struct {
 	Concurrency       int    `koanf:"concurrency"`         // 并发数
 	MaxRetries        int    `koanf:"max_retries"`         // 失败任务最大重试次数
 	LogFile           string `koanf:"log_file"`            // 日志文件路径
+
+	// Regions 启用多区域分片预热，为每个地域各建一个 AGS 客户端；为空时退化为
+	// TencentCloud.Region 单地域模式
+	Regions      []string `koanf:"regions"`
+	RegionPolicy string   `koanf:"region_policy"` // 分片策略: round_robin(默认) / hash
+
+	HelmValues         string `koanf:"helm_values"`          // ociartifact 模式下 helm template 使用的 values 文件路径，空表示使用 chart 默认值
+	MetricsAddr        string `koanf:"metrics_addr"`         // Prometheus /metrics 监听地址，空表示不启用
+	MonitorEnabled     bool   `koanf:"monitor_enabled"`      // 是否把指标推送到腾讯云云监控
+	MonitorNamespace   string `koanf:"monitor_namespace"`    // 云监控自定义指标命名空间，默认 QCE/AGS_PRECACHE
+	MonitorPushSeconds int    `koanf:"monitor_push_seconds"` // 云监控推送周期（秒），默认 60
+
+	// Source 选择镜像来源适配器: tcr_enterprise(默认) / tcr_personal / harbor / dockerhub
+	Source             string `koanf:"source"`
+	RegistryURL        string `koanf:"registry_url"`        // tcr_personal/harbor 的 Registry V2 API 地址，如 "https://ccr.ccs.tencentyun.com"
+	RegistryProject    string `koanf:"registry_project"`    // tcr_personal 的命名空间 / harbor 的 project 名称，空表示不过滤
+	RegistryUsername   string `koanf:"registry_username"`   // tcr_personal/harbor 的 Registry V2 API 账号
+	RegistryPassword   string `koanf:"registry_password"`   // tcr_personal/harbor 的 Registry V2 API 密码
+	DockerHubNamespace string `koanf:"dockerhub_namespace"` // dockerhub 的用户名/组织名
 }
 
 // TencentCloundConfig 腾讯云 Agent Sandbox 配置
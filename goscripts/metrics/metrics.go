@@ -0,0 +1,203 @@
+// Package metrics 为 goscripts 下的命令行工具（目前是 cmd/precache）提供一个轻量级的、
+// 兼容 Prometheus 文本暴露格式的指标登记表，避免引入完整的 client_golang 依赖。
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter 只增不减的计数器
+type Counter struct {
+	value int64
+}
+
+// Inc 计数加一
+func (c *Counter) Inc() { c.Add(1) }
+
+// Add 计数增加 delta
+func (c *Counter) Add(delta int64) { atomic.AddInt64(&c.value, delta) }
+
+// Value 返回当前计数
+func (c *Counter) Value() int64 { return atomic.LoadInt64(&c.value) }
+
+// Gauge 可增可减的瞬时值
+type Gauge struct {
+	value int64
+}
+
+// Set 设置瞬时值
+func (g *Gauge) Set(v int64) { atomic.StoreInt64(&g.value, v) }
+
+// Inc 瞬时值加一
+func (g *Gauge) Inc() { atomic.AddInt64(&g.value, 1) }
+
+// Dec 瞬时值减一
+func (g *Gauge) Dec() { atomic.AddInt64(&g.value, -1) }
+
+// Value 返回当前瞬时值
+func (g *Gauge) Value() int64 { return atomic.LoadInt64(&g.value) }
+
+// defaultHistogramBounds 是 task_duration_seconds 等耗时类直方图的默认桶上界（秒）
+var defaultHistogramBounds = []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300, 600, 1800, 3600}
+
+// Histogram 固定桶边界的直方图，桶边界不含 +Inf（渲染时补上）
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []int64 // buckets[i] 统计 <= bounds[i] 的观测次数（累计型，符合 Prometheus 约定）
+	count   int64
+	sum     float64
+}
+
+// NewHistogram 创建直方图，bounds 为空时使用 defaultHistogramBounds
+func NewHistogram(bounds ...float64) *Histogram {
+	if len(bounds) == 0 {
+		bounds = defaultHistogramBounds
+	}
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	return &Histogram{bounds: sorted, buckets: make([]int64, len(sorted))}
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// snapshot 返回累计桶计数、总数与总和的快照
+func (h *Histogram) snapshot() ([]int64, int64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]int64(nil), h.buckets...), h.count, h.sum
+}
+
+// Registry 登记一组命名指标，Render 时按登记顺序输出 Prometheus 文本暴露格式
+type Registry struct {
+	mu    sync.Mutex
+	names []string
+	kinds map[string]string // name -> "counter"|"gauge"|"histogram"
+	help  map[string]string
+	get   map[string]func() any // 返回 *Counter/*Gauge/*Histogram
+}
+
+// NewRegistry 创建一个空的指标登记表
+func NewRegistry() *Registry {
+	return &Registry{
+		kinds: make(map[string]string),
+		help:  make(map[string]string),
+		get:   make(map[string]func() any),
+	}
+}
+
+func (r *Registry) register(name, kind, help string, get func() any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.kinds[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.kinds[name] = kind
+	r.help[name] = help
+	r.get[name] = get
+}
+
+// NewCounter 创建并登记一个 Counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{}
+	r.register(name, "counter", help, func() any { return c })
+	return c
+}
+
+// NewGauge 创建并登记一个 Gauge
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{}
+	r.register(name, "gauge", help, func() any { return g })
+	return g
+}
+
+// NewHistogram 创建并登记一个 Histogram
+func (r *Registry) NewHistogram(name, help string, bounds ...float64) *Histogram {
+	h := NewHistogram(bounds...)
+	r.register(name, "histogram", help, func() any { return h })
+	return h
+}
+
+// Render 按 Prometheus 文本暴露格式（version=0.0.4）写出当前所有登记指标
+func (r *Registry) Render(w io.Writer) {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	r.mu.Unlock()
+
+	for _, name := range names {
+		r.mu.Lock()
+		kind := r.kinds[name]
+		help := r.help[name]
+		getter := r.get[name]
+		r.mu.Unlock()
+
+		fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+
+		switch v := getter().(type) {
+		case *Counter:
+			fmt.Fprintf(w, "%s %d\n", name, v.Value())
+		case *Gauge:
+			fmt.Fprintf(w, "%s %d\n", name, v.Value())
+		case *Histogram:
+			buckets, count, sum := v.snapshot()
+			for i, bound := range v.bounds {
+				fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatBound(bound), buckets[i])
+			}
+			fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+			fmt.Fprintf(w, "%s_sum %f\n", name, sum)
+			fmt.Fprintf(w, "%s_count %d\n", name, count)
+		}
+	}
+}
+
+// StartServer 启动一个只读的 /metrics HTTP 端点暴露 registry 中的全部指标
+// 返回的 func() 用于关闭端点
+func StartServer(addr string, registry *Registry) (func(), error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		registry.Render(w)
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return func() { _ = server.Close() }, nil
+}
+
+func formatBound(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%d", int64(v))
+	}
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%f", v), "0"), ".")
+}
@@ -0,0 +1,67 @@
+package precache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// helmChartMediaType 是 Helm chart 在 OCI registry 中的 manifest 媒体类型，Harbor 等
+// 制品仓库依据它把 chart 制品仓库和普通镜像仓库区分开
+const helmChartMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+// IsHelmChart 判断某个制品是否是 Helm chart（而非普通镜像），依据 ImageSource 枚举时
+// 记录下的 manifest 媒体类型
+func IsHelmChart(ref ImageRef) bool {
+	return ref.MediaType == helmChartMediaType
+}
+
+// imageFieldPattern 从 helm template 渲染结果中提取 "image: xxx" 字段，不依赖完整的
+// Kubernetes manifest 解析，因为渲染结果里可能包含 helm template 不认识的 CRD 字段
+var imageFieldPattern = regexp.MustCompile(`(?m)^\s*-?\s*image:\s*"?([^"\s]+)"?\s*$`)
+
+// ResolveChartImages 拉取并渲染一个 OCI 形式的 Helm chart（如 "tcr.../charts/foo:1.2.3"），
+// 返回渲染结果中引用的全部镜像，供调用方逐个提交 PreCacheImageTask。valuesFile 为空时使用
+// chart 默认 values
+func ResolveChartImages(ctx context.Context, chartRef ImageRef, valuesFile string) ([]ImageRef, error) {
+	chartURL := chartRef.FullName
+	if !strings.HasPrefix(chartURL, "oci://") {
+		chartURL = "oci://" + chartURL
+	}
+
+	repo, version := chartURL, ""
+	if idx := strings.LastIndex(chartURL, ":"); idx > strings.Index(chartURL, "://")+2 {
+		repo, version = chartURL[:idx], chartURL[idx+1:]
+	}
+
+	args := []string{"template", "precache", repo}
+	if version != "" {
+		args = append(args, "--version", version)
+	}
+	if valuesFile != "" {
+		args = append(args, "--values", valuesFile)
+	}
+
+	cmd := exec.CommandContext(ctx, "helm", args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("helm template 失败: chart=%s: %w", chartRef.FullName, err)
+	}
+
+	seen := make(map[string]struct{})
+	var images []ImageRef
+	for _, match := range imageFieldPattern.FindAllStringSubmatch(string(out), -1) {
+		image := match[1]
+		if _, ok := seen[image]; ok {
+			continue
+		}
+		seen[image] = struct{}{}
+		images = append(images, ImageRef{FullName: image, RegistryType: chartRef.RegistryType})
+	}
+
+	return images, nil
+}
@@ -0,0 +1,286 @@
+// Package precache 将 TCR 镜像遍历、AGS 预热任务提交与 spinner TUI 进度展示串联成一个可复用的批量预热流程。
+package precache
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/panjf2000/ants/v2"
+
+	tuispinner "goscripts/tui/spinner"
+	"goscripts/yunapi/ags"
+	"goscripts/yunapi/tcr"
+)
+
+// Options 批量预热的运行参数
+type Options struct {
+	RegistryID      string         // TCR 实例 ID
+	Namespace       string         // TCR 命名空间
+	RepoFilter      *regexp.Regexp // 仓库名过滤，nil 表示不过滤
+	TagFilter       *regexp.Regexp // 镜像 tag 过滤，nil 表示不过滤
+	Concurrency     int            // 并发数，默认 10
+	PollInterval    time.Duration  // 轮询间隔，默认 5s
+	PerImageTimeout time.Duration  // 单个镜像预热超时时间，默认 30min
+	FailFast        bool           // 是否在第一个失败后停止提交剩余任务
+}
+
+// ImageReport 单个镜像的预热结果
+type ImageReport struct {
+	Image       string `json:"image"`
+	ImageDigest string `json:"image_digest"`
+	Status      string `json:"status"` // Success, Failed
+	ElapsedMs   int64  `json:"elapsed_ms"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Report 批量预热的汇总结果
+type Report struct {
+	Total     int            `json:"total"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Images    []*ImageReport `json:"images"`
+}
+
+// Summary 生成可打印到 TUI 的汇总文本
+func (r *Report) Summary() string {
+	return fmt.Sprintf("预热完成: 总计 %d, 成功 %d, 失败 %d", r.Total, r.Succeeded, r.Failed)
+}
+
+// Orchestrator 串联 TCR 镜像遍历、AGS 预热任务与 spinner 进度展示
+type Orchestrator struct {
+	agsClient *ags.Client
+	tcrClient *tcr.Client
+	spinner   *tuispinner.Manager
+	opts      Options
+
+	pool *ants.Pool
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	results []*ImageReport
+
+	failOnce sync.Once
+	failed   bool
+}
+
+// NewOrchestrator 创建批量预热编排器
+func NewOrchestrator(agsClient *ags.Client, tcrClient *tcr.Client, spinner *tuispinner.Manager, opts Options) (*Orchestrator, error) {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 5 * time.Second
+	}
+	if opts.PerImageTimeout <= 0 {
+		opts.PerImageTimeout = 30 * time.Minute
+	}
+
+	pool, err := ants.NewPool(concurrency, ants.WithPreAlloc(true))
+	if err != nil {
+		return nil, fmt.Errorf("创建预热任务池失败: %w", err)
+	}
+
+	return &Orchestrator{
+		agsClient: agsClient,
+		tcrClient: tcrClient,
+		spinner:   spinner,
+		opts:      opts,
+		pool:      pool,
+	}, nil
+}
+
+// Release 释放任务池
+func (o *Orchestrator) Release() {
+	o.pool.Release()
+}
+
+// Run 遍历 registryName 下的所有镜像并提交预热任务，返回汇总结果
+func (o *Orchestrator) Run(ctx context.Context, registryName string) (*Report, error) {
+	for repo, err := range o.tcrClient.Repositories(ctx, o.opts.RegistryID, o.opts.Namespace) {
+		if err != nil {
+			return nil, fmt.Errorf("遍历镜像仓库失败: %w", err)
+		}
+
+		if o.isFailed() {
+			break
+		}
+
+		repoName := strings.TrimPrefix(*repo.Name, o.opts.Namespace+"/")
+		if o.opts.RepoFilter != nil && !o.opts.RepoFilter.MatchString(repoName) {
+			continue
+		}
+
+		for image, err := range o.tcrClient.RepositoryImages(ctx, o.opts.RegistryID, o.opts.Namespace, repoName) {
+			if err != nil {
+				return nil, fmt.Errorf("遍历仓库镜像失败: repo=%s: %w", repoName, err)
+			}
+
+			if o.isFailed() {
+				break
+			}
+
+			tag := *image.ImageVersion
+			if o.opts.TagFilter != nil && !o.opts.TagFilter.MatchString(tag) {
+				continue
+			}
+
+			fullImageName := fmt.Sprintf("%s.tencentcloudcr.com/%s/%s:%s",
+				registryName, o.opts.Namespace, repoName, tag)
+
+			o.submit(ctx, &ags.Artifact{
+				Type:              ags.ArtifactTypeImage,
+				Image:             fullImageName,
+				ImageRegistryType: "enterprise",
+			})
+		}
+	}
+
+	o.wg.Wait()
+
+	return o.buildReport(), nil
+}
+
+// artifactRef 返回 artifact 对用户有意义的唯一标识，用作 spinner 任务 ID 和报告中的 Image 字段
+func artifactRef(artifact *ags.Artifact) string {
+	if artifact.Type == ags.ArtifactTypeChart {
+		return fmt.Sprintf("%s/%s:%s", artifact.ChartRepo, artifact.ChartName, artifact.ChartVersion)
+	}
+	return artifact.Image
+}
+
+// artifactIcon 给不同类型的预热对象一个区分图标，用于 spinner 进度列表
+func artifactIcon(artifact *ags.Artifact) string {
+	if artifact.Type == ags.ArtifactTypeChart {
+		return "⎈"
+	}
+	return "🐳"
+}
+
+// submit 提交单个对象（镜像或 Chart）的预热任务
+func (o *Orchestrator) submit(ctx context.Context, artifact *ags.Artifact) {
+	ref := artifactRef(artifact)
+	displayName := fmt.Sprintf("%s %s", artifactIcon(artifact), ref)
+
+	o.wg.Add(1)
+	o.spinner.AddTask(ref, displayName)
+
+	err := o.pool.Submit(func() {
+		defer o.wg.Done()
+		o.warmOne(ctx, artifact)
+	})
+	if err != nil {
+		o.wg.Done()
+		o.spinner.FailTask(ref, err.Error())
+		o.recordFailure(ref, "", err)
+	}
+}
+
+// warmOne 创建并轮询单个对象的预热任务
+func (o *Orchestrator) warmOne(ctx context.Context, artifact *ags.Artifact) {
+	ref := artifactRef(artifact)
+	start := time.Now()
+
+	task, err := o.agsClient.PreCache(artifact)
+	if err != nil {
+		o.finish(ref, "", false, time.Since(start), fmt.Errorf("创建预热任务失败: %w", err))
+		return
+	}
+
+	status, err := o.waitComplete(ctx, task)
+
+	o.finish(ref, task.Digest, status == "Success", time.Since(start), err)
+}
+
+// waitComplete 轮询预热任务直到进入终态
+func (o *Orchestrator) waitComplete(ctx context.Context, task *ags.PreCacheTask) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, o.opts.PerImageTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(o.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("等待预热超时: %w", ctx.Err())
+		case <-ticker.C:
+			status, message, err := o.agsClient.DescribePreCache(task)
+			if err != nil {
+				continue
+			}
+
+			switch status {
+			case "Success":
+				return "Success", nil
+			case "Failed":
+				return "Failed", fmt.Errorf("预热失败: %s", message)
+			}
+		}
+	}
+}
+
+// finish 记录单个镜像的最终结果并驱动 spinner
+func (o *Orchestrator) finish(imageRef, imageDigest string, success bool, elapsed time.Duration, err error) {
+	report := &ImageReport{
+		Image:       imageRef,
+		ImageDigest: imageDigest,
+		ElapsedMs:   elapsed.Milliseconds(),
+	}
+
+	if success {
+		report.Status = "Success"
+		o.spinner.FinishTask(imageRef)
+	} else {
+		report.Status = "Failed"
+		if err != nil {
+			report.Error = err.Error()
+		}
+		o.spinner.FailTask(imageRef, report.Error)
+		if o.opts.FailFast {
+			o.failOnce.Do(func() {
+				o.mu.Lock()
+				o.failed = true
+				o.mu.Unlock()
+			})
+		}
+	}
+
+	o.mu.Lock()
+	o.results = append(o.results, report)
+	o.mu.Unlock()
+}
+
+// recordFailure 记录提交阶段（如任务池已被 Release 关闭）的失败
+func (o *Orchestrator) recordFailure(imageRef, imageDigest string, err error) {
+	o.finish(imageRef, imageDigest, false, 0, err)
+}
+
+func (o *Orchestrator) isFailed() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.failed
+}
+
+// buildReport 汇总所有镜像结果
+func (o *Orchestrator) buildReport() *Report {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	report := &Report{
+		Total:  len(o.results),
+		Images: o.results,
+	}
+	for _, r := range o.results {
+		if r.Status == "Success" {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
@@ -0,0 +1,345 @@
+package precache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"goscripts/config"
+	"goscripts/yunapi/tcr"
+)
+
+// ImageRef 是某个镜像源枚举出的一个可预热镜像
+type ImageRef struct {
+	FullName     string // 可直接传给 CreatePreCacheImageTask 的完整镜像地址，如 "repo.example.com/ns/app:v1"
+	RegistryType string // 对应 CreatePreCacheImageTask 的 ImageRegistryType 字段
+	MediaType    string // manifest 媒体类型，用于区分 Helm chart 等 OCI 制品与普通镜像；未知时为空
+}
+
+// ImageSource 枚举某个镜像仓库下全部待预热的镜像，屏蔽 TCR 企业版/个人版、Harbor、Docker Hub
+// 这几种仓库在“列出仓库下所有镜像”这件事上的 API 差异
+type ImageSource interface {
+	ListImages(ctx context.Context) iter.Seq2[ImageRef, error]
+}
+
+// NewImageSource 根据 cfg.Source 选择镜像源适配器；registryName 仅 tcr_enterprise 需要，
+// 由调用方在拿到 TCR 实例信息后传入
+func NewImageSource(cfg config.PrecacheConfg, tcrClient *tcr.Client, registryName string) (ImageSource, error) {
+	switch cfg.Source {
+	case "", "tcr_enterprise":
+		return &tcrEnterpriseSource{
+			client:       tcrClient,
+			registryID:   cfg.TCRRegistryID,
+			namespace:    cfg.TCRNamespace,
+			registryName: registryName,
+		}, nil
+	case "tcr_personal":
+		baseURL := cfg.RegistryURL
+		if baseURL == "" {
+			baseURL = "https://ccr.ccs.tencentyun.com"
+		}
+		return newRegistryV2Source(baseURL, cfg.RegistryProject, cfg.RegistryUsername, cfg.RegistryPassword, "personal")
+	case "harbor":
+		if cfg.RegistryURL == "" {
+			return nil, fmt.Errorf("harbor 镜像源需要配置 registry_url")
+		}
+		return newRegistryV2Source(cfg.RegistryURL, cfg.RegistryProject, cfg.RegistryUsername, cfg.RegistryPassword, "harbor")
+	case "dockerhub":
+		if cfg.DockerHubNamespace == "" {
+			return nil, fmt.Errorf("dockerhub 镜像源需要配置 dockerhub_namespace")
+		}
+		return &dockerHubSource{namespace: cfg.DockerHubNamespace, httpClient: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("未知的镜像源: %s", cfg.Source)
+	}
+}
+
+// ============================================
+// tcr_enterprise: 复用 yunapi/tcr 的 SDK 封装
+// ============================================
+
+type tcrEnterpriseSource struct {
+	client       *tcr.Client
+	registryID   string
+	namespace    string
+	registryName string
+}
+
+func (s *tcrEnterpriseSource) ListImages(ctx context.Context) iter.Seq2[ImageRef, error] {
+	return func(yield func(ImageRef, error) bool) {
+		for repo, err := range s.client.Repositories(ctx, s.registryID, s.namespace) {
+			if err != nil {
+				yield(ImageRef{}, fmt.Errorf("遍历镜像仓库失败: %w", err))
+				return
+			}
+
+			repoName := strings.TrimPrefix(*repo.Name, s.namespace+"/")
+
+			for image, err := range s.client.RepositoryImages(ctx, s.registryID, s.namespace, repoName) {
+				if err != nil {
+					yield(ImageRef{}, fmt.Errorf("遍历仓库镜像失败: repo=%s: %w", repoName, err))
+					return
+				}
+
+				ref := ImageRef{
+					FullName: fmt.Sprintf("%s.tencentcloudcr.com/%s/%s:%s",
+						s.registryName, s.namespace, repoName, *image.ImageVersion),
+					RegistryType: "enterprise",
+				}
+				if !yield(ref, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ============================================
+// tcr_personal / harbor: 标准 Docker Registry HTTP API V2 (_catalog + tags/list)
+// ============================================
+//
+// TCR 个人版与 Harbor 都实现了标准的 Registry V2 协议，因此用同一套基于 basic auth 的
+// 轻量 HTTP 客户端即可驱动两者，只是 baseURL、project 前缀过滤和 registryType 不同。
+
+const registryV2PageSize = 100
+
+type registryV2Source struct {
+	baseURL      string
+	project      string // 非空时只保留该前缀下的仓库，如 harbor 的 project 或 tcr 个人版的命名空间
+	username     string
+	password     string
+	registryType string
+	httpClient   *http.Client
+}
+
+func newRegistryV2Source(baseURL, project, username, password, registryType string) (*registryV2Source, error) {
+	return &registryV2Source{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		project:      project,
+		username:     username,
+		password:     password,
+		registryType: registryType,
+		httpClient:   http.DefaultClient,
+	}, nil
+}
+
+func (s *registryV2Source) ListImages(ctx context.Context) iter.Seq2[ImageRef, error] {
+	return func(yield func(ImageRef, error) bool) {
+		repos, err := s.listRepositories(ctx)
+		if err != nil {
+			yield(ImageRef{}, err)
+			return
+		}
+
+		host := strings.TrimPrefix(strings.TrimPrefix(s.baseURL, "https://"), "http://")
+
+		for _, repo := range repos {
+			if s.project != "" && !strings.HasPrefix(repo, s.project+"/") {
+				continue
+			}
+
+			tags, err := s.listTags(ctx, repo)
+			if err != nil {
+				if !yield(ImageRef{}, fmt.Errorf("查询仓库 tag 列表失败: repo=%s: %w", repo, err)) {
+					return
+				}
+				continue
+			}
+
+			for _, tag := range tags {
+				// media type 查询失败不影响预热本身，忽略错误按普通镜像处理即可
+				mediaType, _ := s.getManifestMediaType(ctx, repo, tag)
+				ref := ImageRef{
+					FullName:     fmt.Sprintf("%s/%s:%s", host, repo, tag),
+					RegistryType: s.registryType,
+					MediaType:    mediaType,
+				}
+				if !yield(ref, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+type registryV2CatalogResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+// listRepositories 分页拉取 _catalog，直到返回的仓库数小于一页
+func (s *registryV2Source) listRepositories(ctx context.Context) ([]string, error) {
+	var all []string
+	last := ""
+	for {
+		query := url.Values{"n": {fmt.Sprintf("%d", registryV2PageSize)}}
+		if last != "" {
+			query.Set("last", last)
+		}
+
+		var page registryV2CatalogResponse
+		if err := s.getJSON(ctx, "/v2/_catalog?"+query.Encode(), &page); err != nil {
+			return nil, fmt.Errorf("查询 _catalog 失败: %w", err)
+		}
+
+		all = append(all, page.Repositories...)
+		if len(page.Repositories) < registryV2PageSize {
+			return all, nil
+		}
+		last = page.Repositories[len(page.Repositories)-1]
+	}
+}
+
+type registryV2TagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
+func (s *registryV2Source) listTags(ctx context.Context, repo string) ([]string, error) {
+	var resp registryV2TagsResponse
+	if err := s.getJSON(ctx, fmt.Sprintf("/v2/%s/tags/list", repo), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+// getManifestMediaType 通过请求 manifest 取其 Content-Type，用于区分 Helm chart 这类 OCI
+// 制品与普通容器镜像（见 IsHelmChart）；这里只关心响应头，manifest body 读出后直接丢弃、不做解析
+func (s *registryV2Source) getManifestMediaType(ctx context.Context, repo, tag string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v2/%s/manifests/%s", s.baseURL, repo, tag), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", strings.Join([]string{
+		helmChartMediaType,
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ", "))
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return resp.Header.Get("Content-Type"), nil
+}
+
+func (s *registryV2Source) getJSON(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ============================================
+// dockerhub: Docker Hub Hub API v2（列出仓库只需公开的 Hub API，无需拉取镜像本身的认证）
+// ============================================
+
+type dockerHubSource struct {
+	namespace  string
+	httpClient *http.Client
+}
+
+type dockerHubRepoPage struct {
+	Next    string             `json:"next"`
+	Results []dockerHubRepoDef `json:"results"`
+}
+
+type dockerHubRepoDef struct {
+	Name string `json:"name"`
+}
+
+type dockerHubTagPage struct {
+	Next    string            `json:"next"`
+	Results []dockerHubTagDef `json:"results"`
+}
+
+type dockerHubTagDef struct {
+	Name string `json:"name"`
+}
+
+func (s *dockerHubSource) ListImages(ctx context.Context) iter.Seq2[ImageRef, error] {
+	return func(yield func(ImageRef, error) bool) {
+		reposURL := fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/?page_size=100", url.PathEscape(s.namespace))
+		for reposURL != "" {
+			var page dockerHubRepoPage
+			if err := s.getJSON(ctx, reposURL, &page); err != nil {
+				yield(ImageRef{}, fmt.Errorf("查询 Docker Hub 仓库列表失败: %w", err))
+				return
+			}
+
+			for _, repo := range page.Results {
+				if !s.yieldTags(ctx, repo.Name, yield) {
+					return
+				}
+			}
+			reposURL = page.Next
+		}
+	}
+}
+
+func (s *dockerHubSource) yieldTags(ctx context.Context, repo string, yield func(ImageRef, error) bool) bool {
+	tagsURL := fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/%s/tags?page_size=100", url.PathEscape(s.namespace), url.PathEscape(repo))
+	for tagsURL != "" {
+		var page dockerHubTagPage
+		if err := s.getJSON(ctx, tagsURL, &page); err != nil {
+			return yield(ImageRef{}, fmt.Errorf("查询仓库 tag 列表失败: repo=%s: %w", repo, err))
+		}
+
+		for _, tag := range page.Results {
+			ref := ImageRef{
+				FullName:     fmt.Sprintf("%s/%s:%s", s.namespace, repo, tag.Name),
+				RegistryType: "dockerhub",
+			}
+			if !yield(ref, nil) {
+				return false
+			}
+		}
+		tagsURL = page.Next
+	}
+	return true
+}
+
+func (s *dockerHubSource) getJSON(ctx context.Context, rawURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
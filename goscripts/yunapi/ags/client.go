@@ -10,6 +10,7 @@ import (
 	tcerrors "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/regions"
 	"golang.org/x/time/rate"
 
 	"goscripts/config"
@@ -20,8 +21,44 @@ const (
 	APIVersion  = "2025-09-20"
 )
 
-// NewClient 创建 AGS 客户端
+// validRegions 是 SDK 内置的地域常量集合，用于在构造客户端前校验地域名称，避免
+// PrecacheConfg.Regions 里的拼写错误直到真正发起请求才报错
+var validRegions = map[string]struct{}{
+	regions.Guangzhou:     {},
+	regions.Shanghai:      {},
+	regions.Beijing:       {},
+	regions.Chengdu:       {},
+	regions.Chongqing:     {},
+	regions.HongKong:      {},
+	regions.Singapore:     {},
+	regions.Tokyo:         {},
+	regions.Seoul:         {},
+	regions.Bangkok:       {},
+	regions.Mumbai:        {},
+	regions.Frankfurt:     {},
+	regions.Moscow:        {},
+	regions.SiliconValley: {},
+	regions.Toronto:       {},
+}
+
+// IsValidRegion 判断 region 是否是 Tencent Cloud SDK 已知的地域
+func IsValidRegion(region string) bool {
+	_, ok := validRegions[region]
+	return ok
+}
+
+// NewClient 创建使用 TencentCloud.Region 的 AGS 客户端
 func NewClient() (*Client, error) {
+	return NewClientForRegion(config.C.TencentCloud.Region)
+}
+
+// NewClientForRegion 创建请求指定地域的 AGS 客户端，每个客户端各自持有独立的限流器，
+// 供多地域分片预热（见 PrecacheConfg.Regions）为每个地域单独限流
+func NewClientForRegion(region string) (*Client, error) {
+	if !IsValidRegion(region) {
+		return nil, fmt.Errorf("未知的地域: %s", region)
+	}
+
 	credential := common.NewCredential(
 		config.C.TencentCloud.SecretID,
 		config.C.TencentCloud.SecretKey,
@@ -35,25 +72,26 @@ func NewClient() (*Client, error) {
 	case "pre":
 		cpf.HttpProfile.Endpoint = "ags.pre.tencentcloudapi.woa.com"
 	case "internal":
-		cpf.HttpProfile.Endpoint = fmt.Sprintf("ags.%s.tencentcloudapi.woa.com", config.C.TencentCloud.Region)
+		cpf.HttpProfile.Endpoint = fmt.Sprintf("ags.%s.tencentcloudapi.woa.com", region)
 	default:
 		cpf.HttpProfile.Endpoint = "ags.tencentcloudapi.com"
 	}
 
-	sdkClient, err := ags.NewClient(credential, config.C.TencentCloud.Region, cpf)
+	sdkClient, err := ags.NewClient(credential, region, cpf)
 	if err != nil {
 		return nil, fmt.Errorf("创建 AGS SDK 客户端失败: %w", err)
 	}
 
 	// 初始化通用客户端用于调用未封装的接口
 	var commonClient common.Client
-	commonClient.Init(config.C.TencentCloud.Region).
+	commonClient.Init(region).
 		WithCredential(credential).
 		WithProfile(cpf)
 
 	return &Client{
 		Client:       sdkClient,
 		commonClient: &commonClient,
+		region:       region,
 		limiter:      rate.NewLimiter(rate.Limit(5), 5),
 	}, nil
 }
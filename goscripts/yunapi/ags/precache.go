@@ -67,3 +67,168 @@ func (c *Client) DescribePreCacheImageTask(req *DescribePreCacheImageTaskRequest
 	}
 	return &resp, nil
 }
+
+// CreatePreCacheChartTaskRequest 创建 Helm Chart 预热任务请求参数
+type CreatePreCacheChartTaskRequest struct {
+	ChartRepo         string `json:"ChartRepo"`         // Chart 仓库地址，如 "oci://xxx.tencentcloudcr.com/charts"
+	ChartName         string `json:"ChartName"`         // Chart 名称
+	ChartVersion      string `json:"ChartVersion"`      // Chart 版本
+	ImageRegistryType string `json:"ImageRegistryType"` // 镜像仓库类型，如 "TCR"
+}
+
+// CreatePreCacheChartTaskResponse 创建 Helm Chart 预热任务响应
+type CreatePreCacheChartTaskResponse struct {
+	Response struct {
+		ChartRepo         string `json:"ChartRepo"`
+		ChartName         string `json:"ChartName"`
+		ChartVersion      string `json:"ChartVersion"`
+		ChartDigest       string `json:"ChartDigest"`
+		ImageRegistryType string `json:"ImageRegistryType"`
+		RequestId         string `json:"RequestId"` // 请求ID
+	} `json:"Response"`
+}
+
+// CreatePreCacheChartTask 创建 Helm Chart 预热任务
+func (c *Client) CreatePreCacheChartTask(req *CreatePreCacheChartTaskRequest) (*CreatePreCacheChartTaskResponse, error) {
+	params := map[string]any{
+		"ChartRepo":         req.ChartRepo,
+		"ChartName":         req.ChartName,
+		"ChartVersion":      req.ChartVersion,
+		"ImageRegistryType": req.ImageRegistryType,
+	}
+
+	var resp CreatePreCacheChartTaskResponse
+	if err := c.CallWithResponse("CreatePreCacheChartTask", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DescribePreCacheChartTaskRequest 查询 Helm Chart 预热任务请求参数
+type DescribePreCacheChartTaskRequest struct {
+	ChartRepo         string  `json:"ChartRepo"`
+	ChartName         string  `json:"ChartName"`
+	ChartVersion      string  `json:"ChartVersion"`
+	ChartDigest       *string `json:"ChartDigest,omitempty"` // Chart 摘要，如 "sha256:abcdefg123..."
+	ImageRegistryType string  `json:"ImageRegistryType"`
+}
+
+// DescribePreCacheChartTaskResponse 查询 Helm Chart 预热任务响应
+type DescribePreCacheChartTaskResponse struct {
+	Response struct {
+		ChartRepo         string `json:"ChartRepo"`
+		ChartName         string `json:"ChartName"`
+		ChartVersion      string `json:"ChartVersion"`
+		ChartDigest       string `json:"ChartDigest"`
+		ImageRegistryType string `json:"ImageRegistryType"`
+		Status            string `json:"Status"`
+		Message           string `json:"Message"`
+		RequestId         string `json:"RequestId"`
+	} `json:"Response"`
+}
+
+// DescribePreCacheChartTask 查询 Helm Chart 预热任务
+func (c *Client) DescribePreCacheChartTask(req *DescribePreCacheChartTaskRequest) (*DescribePreCacheChartTaskResponse, error) {
+	params := map[string]any{
+		"ChartRepo":         req.ChartRepo,
+		"ChartName":         req.ChartName,
+		"ChartVersion":      req.ChartVersion,
+		"ImageRegistryType": req.ImageRegistryType,
+	}
+	if req.ChartDigest != nil {
+		params["ChartDigest"] = *req.ChartDigest
+	}
+
+	var resp DescribePreCacheChartTaskResponse
+	if err := c.CallWithResponse("DescribePreCacheChartTask", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ArtifactType 区分预热对象是容器镜像还是 Helm Chart
+type ArtifactType string
+
+const (
+	ArtifactTypeImage ArtifactType = "image"
+	ArtifactTypeChart ArtifactType = "chart"
+)
+
+// Artifact 描述一个可预热的对象，Type 决定 Image 或 Chart* 字段中哪一组生效
+type Artifact struct {
+	Type              ArtifactType
+	Image             string // Type == ArtifactTypeImage 时必填
+	ChartRepo         string // Type == ArtifactTypeChart 时必填
+	ChartName         string
+	ChartVersion      string
+	ImageRegistryType string
+}
+
+// PreCacheTask 屏蔽镜像预热和 Chart 预热两种底层响应差异后的统一结果
+type PreCacheTask struct {
+	Artifact          *Artifact
+	Digest            string
+	ImageRegistryType string
+}
+
+// PreCache 根据 artifact.Type 创建镜像预热任务或 Chart 预热任务
+func (c *Client) PreCache(artifact *Artifact) (*PreCacheTask, error) {
+	switch artifact.Type {
+	case ArtifactTypeChart:
+		resp, err := c.CreatePreCacheChartTask(&CreatePreCacheChartTaskRequest{
+			ChartRepo:         artifact.ChartRepo,
+			ChartName:         artifact.ChartName,
+			ChartVersion:      artifact.ChartVersion,
+			ImageRegistryType: artifact.ImageRegistryType,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &PreCacheTask{
+			Artifact:          artifact,
+			Digest:            resp.Response.ChartDigest,
+			ImageRegistryType: resp.Response.ImageRegistryType,
+		}, nil
+	default:
+		resp, err := c.CreatePreCacheImageTask(&CreatePreCacheImageTaskRequest{
+			Image:             artifact.Image,
+			ImageRegistryType: artifact.ImageRegistryType,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &PreCacheTask{
+			Artifact:          artifact,
+			Digest:            resp.Response.ImageDigest,
+			ImageRegistryType: resp.Response.ImageRegistryType,
+		}, nil
+	}
+}
+
+// DescribePreCache 根据 task.Artifact.Type 查询镜像预热或 Chart 预热任务的状态
+func (c *Client) DescribePreCache(task *PreCacheTask) (status, message string, err error) {
+	switch task.Artifact.Type {
+	case ArtifactTypeChart:
+		resp, err := c.DescribePreCacheChartTask(&DescribePreCacheChartTaskRequest{
+			ChartRepo:         task.Artifact.ChartRepo,
+			ChartName:         task.Artifact.ChartName,
+			ChartVersion:      task.Artifact.ChartVersion,
+			ChartDigest:       &task.Digest,
+			ImageRegistryType: task.ImageRegistryType,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		return resp.Response.Status, resp.Response.Message, nil
+	default:
+		resp, err := c.DescribePreCacheImageTask(&DescribePreCacheImageTaskRequest{
+			Image:             task.Artifact.Image,
+			ImageDigest:       &task.Digest,
+			ImageRegistryType: task.ImageRegistryType,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		return resp.Response.Status, resp.Response.Message, nil
+	}
+}
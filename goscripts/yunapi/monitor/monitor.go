@@ -0,0 +1,208 @@
+// Package monitor 封装腾讯云可观测平台（云监控）自定义指标上报接口，
+// 用于把 Precacher 等命令行工具的运行指标以 QCE/AGS_PRECACHE 命名空间下的自定义指标形式
+// 推送到云监控，便于和已有的腾讯云产品监控面板放在一起看。
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tcerrors "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	"golang.org/x/time/rate"
+
+	"goscripts/config"
+)
+
+// DefaultNamespace 是 Precacher 自定义指标使用的云监控命名空间
+const DefaultNamespace = "QCE/AGS_PRECACHE"
+
+const (
+	serviceName = "monitor"
+	apiVersion  = "2018-07-24"
+)
+
+// Client 云监控客户端封装。云监控自定义指标写入接口（PutMonitorData）在这个 SDK 版本里
+// 没有生成强类型的 Request/Response，因此和 yunapi/ags.Client.CallWithResponse 一样，
+// 直接用 common.Client 拼 CommonRequest 调用
+type Client struct {
+	commonClient *common.Client
+	limiter      *rate.Limiter
+}
+
+// NewClient 创建云监控客户端
+func NewClient() (*Client, error) {
+	credential := common.NewCredential(
+		config.C.TencentCloud.SecretID,
+		config.C.TencentCloud.SecretKey,
+	)
+
+	var commonClient common.Client
+	commonClient.Init(config.C.TencentCloud.Region).
+		WithCredential(credential).
+		WithProfile(profile.NewClientProfile())
+
+	return &Client{commonClient: &commonClient, limiter: rate.NewLimiter(rate.Limit(5), 5)}, nil
+}
+
+// MetricPoint 是一条自定义指标采样，上报时同一批次内 MetricName 相同的全部采样点会合并为一条 MetricDatum
+type MetricPoint struct {
+	MetricName string
+	Value      float64
+	Timestamp  time.Time
+}
+
+// metricDatumParam 对应 PutMonitorData 接口里的 Metrics[].MetricDatum 结构
+type metricDatumParam struct {
+	MetricName string    `json:"MetricName"`
+	Timestamps []int64   `json:"Timestamps"`
+	Values     []float64 `json:"Values"`
+}
+
+// PutMonitorData 把一批自定义指标采样以 namespace 命名空间上报到云监控
+func (c *Client) PutMonitorData(namespace string, points []MetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	if err := c.limiter.Wait(context.Background()); err != nil {
+		return err
+	}
+
+	grouped := make(map[string][]MetricPoint, len(points))
+	for _, p := range points {
+		grouped[p.MetricName] = append(grouped[p.MetricName], p)
+	}
+
+	metrics := make([]metricDatumParam, 0, len(grouped))
+	for name, samples := range grouped {
+		datum := metricDatumParam{MetricName: name}
+		for _, s := range samples {
+			datum.Timestamps = append(datum.Timestamps, s.Timestamp.Unix())
+			datum.Values = append(datum.Values, s.Value)
+		}
+		metrics = append(metrics, datum)
+	}
+
+	params := map[string]any{
+		"Namespace": namespace,
+		"Metrics":   metrics,
+	}
+
+	paramsBytes, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("序列化请求参数失败: %w", err)
+	}
+
+	request := tchttp.NewCommonRequest(serviceName, apiVersion, "PutMonitorData")
+	if err := request.SetActionParameters(paramsBytes); err != nil {
+		return fmt.Errorf("设置请求参数失败: %w", err)
+	}
+
+	response := tchttp.NewCommonResponse()
+	if err := c.commonClient.Send(request, response); err != nil {
+		return fmt.Errorf("上报云监控自定义指标失败: %w", err)
+	}
+
+	var commonResp struct {
+		Response struct {
+			Error *struct {
+				Code    string `json:"Code"`
+				Message string `json:"Message"`
+			} `json:"Error,omitempty"`
+			RequestId string `json:"RequestId"`
+		} `json:"Response"`
+	}
+	if err := json.Unmarshal(response.GetBody(), &commonResp); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	if commonResp.Response.Error != nil {
+		return tcerrors.NewTencentCloudSDKError(
+			commonResp.Response.Error.Code,
+			commonResp.Response.Error.Message,
+			commonResp.Response.RequestId,
+		)
+	}
+
+	return nil
+}
+
+// Pusher 按固定周期把采集到的指标批量推送到云监控，推送失败不阻塞采集方
+type Pusher struct {
+	client    *Client
+	namespace string
+	interval  time.Duration
+
+	mu      sync.Mutex
+	pending []MetricPoint
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewPusher 创建一个 Pusher，namespace 为空时使用 DefaultNamespace
+func NewPusher(client *Client, namespace string, interval time.Duration) *Pusher {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Pusher{
+		client:    client,
+		namespace: namespace,
+		interval:  interval,
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+}
+
+// Record 记录一个指标采样，采样会在下一个推送周期被批量上报
+func (p *Pusher) Record(metricName string, value float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, MetricPoint{MetricName: metricName, Value: value, Timestamp: time.Now()})
+}
+
+// Start 启动后台推送循环
+func (p *Pusher) Start() {
+	go func() {
+		defer close(p.doneCh)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.flush()
+			case <-p.stopCh:
+				p.flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止推送循环并等待最后一次推送完成
+func (p *Pusher) Stop() {
+	close(p.stopCh)
+	<-p.doneCh
+}
+
+func (p *Pusher) flush() {
+	p.mu.Lock()
+	batch := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+
+	if err := p.client.PutMonitorData(p.namespace, batch); err != nil {
+		// 推送失败不应该拖慢或中断主流程，丢弃该批次并记录日志
+		log.Printf("推送云监控自定义指标失败: %v", err)
+	}
+}